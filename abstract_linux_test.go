@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// NewAbstractUnixDomainSocketServer is like NewUnixDomainSocketServer,
+// but listens on the abstract socket @name, so there is no socket file
+// to delete. Abstract sockets only exist on Linux.
+func NewAbstractUnixDomainSocketServer(name string, handler http.Handler) *httptest.Server {
+	sockPath := "@" + name
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		panic(fmt.Sprintf("httptest: failed to listen on unix domain socket %v: %v", sockPath, err))
+	}
+
+	// Create a UDS-based mock http server.
+	ts := &httptest.Server{
+		Listener: l,
+		Config:   &http.Server{Handler: jsonByDefault(handler)},
+	}
+
+	// Run the server.
+	ts.Start()
+
+	return ts
+}
+
+func TestAbstractSocket(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+	})
+
+	// Abstract names are shared by the whole machine, so make it
+	// unique to this process.
+	fakeServer := NewAbstractUnixDomainSocketServer(fmt.Sprintf("golang-uds-http-client-test-%d", os.Getpid()), router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources.
+	defer fakeServer.Close()
+
+	// The format of the URL is 'http://@golang-uds-...', the part
+	// after '//' is the "@"-prefixed name, used unchanged.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+	assert.True(t, strings.HasPrefix(sock, "@"))
+
+	// Calling functions to be tested.
+	users, err := GetUsers(sock)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Jack"}, users)
+
+	user, err := CreateUser(sock, "Jack")
+	assert.NoError(t, err)
+	assert.Equal(t, &CreateUserResponse{ID: "id_foo", Name: "Jack"}, user)
+
+	// There is no socket file left behind.
+	assert.NoFileExists(t, sock)
+}
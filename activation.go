@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd
+// socket activation, after stdin, stdout and stderr.
+const listenFdsStart = 3
+
+// ErrNoActivation is returned by ClientFromActivation when no socket
+// was passed to the process by socket activation.
+var ErrNoActivation = errors.New("no socket passed by socket activation")
+
+// errActivationConnGone is the dial error of a Client created by
+// ClientFromActivation once its connection is gone.
+var errActivationConnGone = errors.New("the connection passed by socket activation is closed")
+
+// ClientFromActivation creates a Client sending its requests over the
+// connected unix socket passed to the process by systemd socket
+// activation, as told by the LISTEN_PID and LISTEN_FDS environment
+// variables, instead of dialing a path. The first passed socket is
+// used. The variables are unset, so that child processes do not take
+// the socket too.
+//
+// There is a single connection: the requests are sent over it one at
+// a time, and once it is closed, by Close or by the server, every
+// request fails with a *ConnectError.
+func ClientFromActivation(opts ...Option) (*Client, error) {
+	return clientFromActivation(listenFdsStart, opts...)
+}
+
+// clientFromActivation is like ClientFromActivation, the sockets
+// being passed from fd on.
+func clientFromActivation(fd int, opts ...Option) (*Client, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, ErrNoActivation
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, ErrNoActivation
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	// FileConn works on a duplicate of the fd, the original is
+	// not needed anymore.
+	name := "LISTEN_FD_" + strconv.Itoa(fd)
+	f := os.NewFile(uintptr(fd), name)
+	defer f.Close()
+	conn, err := net.FileConn(f)
+	if err != nil {
+		return nil, fmt.Errorf("adopt %s: %w", name, err)
+	}
+
+	c := NewClient(name, append(opts, withConn(conn))...)
+
+	// Requests must wait for the connection rather than dial
+	// another one.
+	c.transport.MaxConnsPerHost = 1
+	return c, nil
+}
+
+// withConn makes the Client use conn for its first connection
+// instead of dialing, and fail to dial any other.
+func withConn(conn net.Conn) Option {
+	return func(c *Client) {
+		var once sync.Once
+		c.dial = func(ctx context.Context, sock string) (net.Conn, error) {
+			var got net.Conn
+			once.Do(func() {
+				got = conn
+			})
+			if got == nil {
+				return nil, errActivationConnGone
+			}
+			return got, nil
+		}
+	}
+}
@@ -0,0 +1,129 @@
+//go:build !windows
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// singleConnListener is a net.Listener accepting conn only, then
+// blocking until it is closed.
+type singleConnListener struct {
+	conn   net.Conn
+	accept sync.Once
+	close  sync.Once
+	closed chan struct{}
+}
+
+func newSingleConnListener(conn net.Conn) *singleConnListener {
+	return &singleConnListener{conn: conn, closed: make(chan struct{})}
+}
+
+func (l *singleConnListener) Accept() (net.Conn, error) {
+	var conn net.Conn
+	l.accept.Do(func() {
+		conn = l.conn
+	})
+	if conn != nil {
+		return conn, nil
+	}
+	<-l.closed
+	return nil, net.ErrClosed
+}
+
+func (l *singleConnListener) Close() error {
+	l.close.Do(func() {
+		close(l.closed)
+	})
+	return nil
+}
+
+func (l *singleConnListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+func TestClientFromActivation(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// newActivation passes one end of a socketpair to the process
+	// the way systemd would, the server serving the other end. It
+	// returns the fd passed.
+	newActivation := func(t *testing.T) int {
+		fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		f := os.NewFile(uintptr(fds[1]), "server")
+		conn, err := net.FileConn(f)
+		f.Close()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		fakeServer := NewUnixDomainSocketServerWithListener(newSingleConnListener(conn), router)
+		t.Cleanup(fakeServer.Close)
+
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		t.Setenv("LISTEN_FDS", "1")
+		return fds[0]
+	}
+
+	t.Run("happy path, the calls share the passed socket", func(t *testing.T) {
+		fd := newActivation(t)
+
+		// Calling the function to be tested.
+		client, err := clientFromActivation(fd)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// The variables are not left to child processes.
+		_, ok := os.LookupEnv("LISTEN_PID")
+		assert.False(t, ok)
+		_, ok = os.LookupEnv("LISTEN_FDS")
+		assert.False(t, ok)
+
+		for i := 0; i < 2; i++ {
+			users, err := client.GetUsers()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"Jack"}, users)
+		}
+
+		// There is no other connection once it is closed.
+		assert.NoError(t, client.Close())
+		_, err = client.GetUsers()
+		var connErr *ConnectError
+		assert.ErrorAs(t, err, &connErr)
+	})
+
+	t.Run("unhappy path, the sockets are for another process", func(t *testing.T) {
+		fd := newActivation(t)
+		defer syscall.Close(fd)
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+
+		_, err := clientFromActivation(fd)
+
+		assert.ErrorIs(t, err, ErrNoActivation)
+	})
+
+	t.Run("unhappy path, no socket passed", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		t.Setenv("LISTEN_FDS", "0")
+
+		_, err := ClientFromActivation()
+
+		assert.ErrorIs(t, err, ErrNoActivation)
+	})
+}
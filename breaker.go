@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, without dialing, by the requests of a
+// Client whose circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of the circuit breaker of a Client.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen fails every request with ErrCircuitOpen.
+	CircuitOpen
+
+	// CircuitHalfOpen lets a single request through to probe the
+	// server. It closes the circuit if it succeeds, and opens it
+	// again otherwise.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// WithCircuitBreaker opens the circuit after threshold requests in a
+// row failed, i.e. the socket could not be reached or the server
+// answered with a 5xx status. The requests then fail with
+// ErrCircuitOpen for cooldown, sparing a server that is down the
+// load of the calls and their retries, before a single request is let
+// through to probe it. A threshold below 1, the default, disables the
+// circuit breaker.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *Client) {
+		if threshold < 1 {
+			c.breaker = nil
+			return
+		}
+		c.breaker = &breaker{threshold: threshold, cooldown: cooldown}
+	}
+}
+
+// CircuitState returns the current state of the circuit breaker of
+// the Client, which is always CircuitClosed without
+// WithCircuitBreaker. An open circuit whose cooldown is over is
+// reported as CircuitHalfOpen, as the next request probes the server.
+func (c *Client) CircuitState() CircuitState {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+	return c.breaker.state()
+}
+
+// breaker is the circuit breaker of a Client. A nil *breaker lets
+// every request through.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	open     bool
+	failures int
+	openedAt time.Time
+
+	// probing is set while the request probing the server is in
+	// flight, the others fail meanwhile.
+	probing bool
+}
+
+func (b *breaker) state() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case !b.open:
+		return CircuitClosed
+	case b.probing || time.Since(b.openedAt) >= b.cooldown:
+		return CircuitHalfOpen
+	}
+	return CircuitOpen
+}
+
+// allow returns ErrCircuitOpen if a request may not be sent now.
+// Otherwise, the outcome of the request must be told to done.
+func (b *breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return nil
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// done records the outcome of a request allowed through, the attempt
+// of req that ended with resp or err. It reports whether the circuit
+// is open after it.
+func (b *breaker) done(req *http.Request, resp *http.Response, err error) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case err != nil && errors.Is(req.Context().Err(), context.Canceled):
+		// The caller gave up, it tells nothing about the
+		// server. Let the next request probe it.
+		b.probing = false
+	case err != nil || resp.StatusCode >= 500:
+		b.failures++
+		if b.probing || b.failures >= b.threshold {
+			b.open = true
+			b.openedAt = time.Now()
+		}
+		b.probing = false
+	default:
+		b.open = false
+		b.failures = 0
+		b.probing = false
+	}
+	return b.open
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler fails while down is set, and counts the requests
+	// reaching it.
+	var down, requests int32
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"msg": "database is down"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+	router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"msg": "user not found"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	const cooldown = 50 * time.Millisecond
+
+	t.Run("happy path, closed -> open -> half-open -> closed", func(t *testing.T) {
+		atomic.StoreInt32(&down, 1)
+		atomic.StoreInt32(&requests, 0)
+		client := NewClient(sock, WithCircuitBreaker(2, cooldown))
+		defer client.Close()
+		assert.Equal(t, CircuitClosed, client.CircuitState())
+
+		// The failures below the threshold keep it closed.
+		_, err := client.GetUsers()
+		assert.Error(t, err)
+		assert.Equal(t, CircuitClosed, client.CircuitState())
+
+		// The threshold opens it.
+		_, err = client.GetUsers()
+		assert.Error(t, err)
+		assert.Equal(t, CircuitOpen, client.CircuitState())
+
+		// The requests fail fast, the server is not reached.
+		_, err = client.GetUsers()
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+		_, err = client.CreateUser("Jack")
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+		// After the cooldown, the next request probes the server.
+		time.Sleep(cooldown)
+		assert.Equal(t, CircuitHalfOpen, client.CircuitState())
+		atomic.StoreInt32(&down, 0)
+		users, err := client.GetUsers()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+		assert.Equal(t, CircuitClosed, client.CircuitState())
+		assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("unhappy path, a failed probe opens it again", func(t *testing.T) {
+		atomic.StoreInt32(&down, 1)
+		atomic.StoreInt32(&requests, 0)
+		client := NewClient(sock, WithCircuitBreaker(1, cooldown))
+		defer client.Close()
+
+		_, err := client.GetUsers()
+		assert.Error(t, err)
+		assert.Equal(t, CircuitOpen, client.CircuitState())
+
+		// The probe fails, and the cooldown starts over.
+		time.Sleep(cooldown)
+		_, err = client.GetUsers()
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, CircuitOpen, client.CircuitState())
+		_, err = client.GetUsers()
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("happy path, the retries stop once it opens", func(t *testing.T) {
+		atomic.StoreInt32(&down, 1)
+		atomic.StoreInt32(&requests, 0)
+		client := NewClient(sock, WithCircuitBreaker(2, cooldown), WithRetry(5, time.Millisecond))
+		defer client.Close()
+
+		_, err := client.GetUsers()
+
+		var apiErr *APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+		}
+		assert.Equal(t, CircuitOpen, client.CircuitState())
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("happy path, client errors do not count", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		client := NewClient(sock, WithCircuitBreaker(1, cooldown))
+		defer client.Close()
+
+		for i := 0; i < 3; i++ {
+			_, err := client.GetUser("XYZ-999")
+			assert.ErrorIs(t, err, ErrUserNotFound)
+		}
+		assert.Equal(t, CircuitClosed, client.CircuitState())
+		assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("unhappy path, an unreachable socket counts", func(t *testing.T) {
+		client := NewClient("/nonexistent/dummy.sock", WithCircuitBreaker(1, time.Hour))
+		defer client.Close()
+
+		_, err := client.GetUsers()
+		var connErr *ConnectError
+		assert.ErrorAs(t, err, &connErr)
+
+		_, err = client.GetUsers()
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+	})
+}
+
+func TestCircuitStateString(t *testing.T) {
+	assert.Equal(t, "closed", CircuitClosed.String())
+	assert.Equal(t, "open", CircuitOpen.String())
+	assert.Equal(t, "half-open", CircuitHalfOpen.String())
+}
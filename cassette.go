@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Mode tells a cassette whether to record or to replay interactions.
+type Mode int
+
+const (
+	// ModeRecord sends requests to the server and writes every
+	// request/response pair to the cassette file.
+	ModeRecord Mode = iota
+
+	// ModeReplay answers requests from the cassette file without
+	// touching the socket.
+	ModeReplay
+)
+
+// WithCassette records the interactions of the Client to the file at
+// path, or replays them from it, depending on mode. Replaying lets
+// tests run without a live server.
+func WithCassette(path string, mode Mode) Option {
+	return func(c *Client) {
+		c.cassette = &cassette{path: path, mode: mode}
+	}
+}
+
+// interaction is a recorded request/response pair.
+type interaction struct {
+	Method      string      `json:"method"`
+	URI         string      `json:"uri"`
+	RequestBody string      `json:"request_body,omitempty"`
+	StatusCode  int         `json:"status_code"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        string      `json:"body"`
+}
+
+// cassette is an http.RoundTripper recording to, or replaying from,
+// a file.
+type cassette struct {
+	path string
+	mode Mode
+
+	// next sends the requests in ModeRecord.
+	next http.RoundTripper
+
+	mu           sync.Mutex
+	loaded       bool
+	interactions []interaction
+	// used marks the interactions already replayed, so repeated
+	// identical requests are answered in recorded order.
+	used []bool
+}
+
+func (c *cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	if c.mode == ModeReplay {
+		return c.replay(req, string(reqBody))
+	}
+	return c.record(req, string(reqBody))
+}
+
+func (c *cassette) record(req *http.Request, reqBody string) (*http.Response, error) {
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// The body is consumed to be recorded, hand a copy back.
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, interaction{
+		Method:      req.Method,
+		URI:         req.URL.RequestURI(),
+		RequestBody: reqBody,
+		StatusCode:  resp.StatusCode,
+		Header:      resp.Header,
+		Body:        string(body),
+	})
+
+	// Rewrite the whole file, so it is complete whenever the
+	// recording stops.
+	data, err := json.MarshalIndent(c.interactions, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(c.path, data, 0o644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *cassette) replay(req *http.Request, reqBody string) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.loaded {
+		data, err := os.ReadFile(c.path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &c.interactions); err != nil {
+			return nil, fmt.Errorf("cassette %s: %w", c.path, err)
+		}
+		c.used = make([]bool, len(c.interactions))
+		c.loaded = true
+	}
+
+	for i, in := range c.interactions {
+		if c.used[i] || in.Method != req.Method || in.URI != req.URL.RequestURI() || in.RequestBody != reqBody {
+			continue
+		}
+		c.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+			StatusCode:    in.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader([]byte(in.Body))),
+			ContentLength: int64(len(in.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("cassette %s: no recorded interaction for %s %s", c.path, req.Method, req.URL.RequestURI())
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack", "Marry", "Sandy"]`))
+	})
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"name": "Jack"}`, string(body))
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	// Record the interactions against the live server.
+	recorder := NewClient(sock, WithCassette(path, ModeRecord))
+	users, err := recorder.GetUsers()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Jack", "Marry", "Sandy"}, users)
+	_, err = recorder.CreateUser("Jack")
+	assert.NoError(t, err)
+
+	// Stop the server, from now on nothing listens on the socket.
+	fakeServer.Close()
+
+	t.Run("replay recorded interactions offline", func(t *testing.T) {
+		player := NewClient(sock, WithCassette(path, ModeReplay))
+
+		users, err := player.GetUsers()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack", "Marry", "Sandy"}, users)
+
+		user, err := player.CreateUser("Jack")
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "id_foo", Name: "Jack"}, user)
+	})
+
+	t.Run("unrecorded request fails", func(t *testing.T) {
+		player := NewClient(sock, WithCassette(path, ModeReplay))
+
+		// The recorded payload was for Jack, not Sandy.
+		_, err := player.CreateUser("Sandy")
+		assert.ErrorContains(t, err, "no recorded interaction for POST /api/v1/user")
+	})
+}
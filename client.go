@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// version is the version of this client, sent in the default
+// User-Agent.
+const version = "0.1.0"
+
+// defaultUserAgent is the User-Agent sent unless WithUserAgent says
+// otherwise.
+const defaultUserAgent = "uds-http-client/" + version
+
+// defaultMaxRedirects is the number of redirects a request may follow
+// before it is abandoned, unless WithMaxRedirects says otherwise.
+const defaultMaxRedirects = 10
+
+// defaultBulkWorkers is the number of requests DeleteUsers has in
+// flight at once, unless WithBulkWorkers says otherwise.
+const defaultBulkWorkers = 4
+
+// ErrTooManyRedirects is returned when the server keeps redirecting
+// a request, e.g. a misconfigured endpoint redirecting to itself.
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// ErrRequestTooLarge is returned, before anything is sent, when a
+// request body is larger than allowed WithMaxRequestBytes. Splitting a
+// batch, e.g. with CreateUsersChunked, keeps each request under the
+// limit.
+var ErrRequestTooLarge = errors.New("request too large")
+
+// Client talks to the API server over a unix domain socket.
+//
+// The underlying http client and its transport are created once, so
+// connections are pooled and reused across calls. A Client is safe
+// for concurrent use by multiple goroutines, and is meant to be
+// reused until Close is called on shutdown.
+type Client struct {
+	httpClient *http.Client
+	transport  *http.Transport
+
+	maxRedirects int
+	cassette     *cassette
+	pretty       bool
+	preflight    bool
+	timeout      time.Duration
+	autoUnwrap   bool
+	headers      http.Header
+	retryCount   int
+	retryBackoff time.Duration
+	retryRand    *lockedRand
+
+	maxRequestBytes     int64
+	maxResponseBytes    int64
+	logger              Logger
+	userAgent           string
+	requestID           func() string
+	tlsConfig           *tls.Config
+	tlsHandshakeTimeout time.Duration
+	insecureSkipVerify  bool
+	host                string
+	metrics             Metrics
+	strict              bool
+	idempotencyKeys     bool
+	disableKeepAlives   bool
+	basePath            string
+	lenientContentType  bool
+	contentType         ContentType
+	codec               Codec
+	bulkWorkers         int
+	breaker             *breaker
+	debug               *debugTap
+	roundTripper        http.RoundTripper
+	jar                 http.CookieJar
+	failover            []string
+	signer              RequestSigner
+
+	// dial connects to the socket, dialSocket unless set
+	// WithDialContext or by ClientFromActivation.
+	dial func(ctx context.Context, sock string) (net.Conn, error)
+
+	// sockErr, if set, fails every request before dialing.
+	sockErr error
+
+	mu        sync.Mutex
+	lastStats Stats
+	counters  counters
+}
+
+// NewClient creates a Client that sends every request over the unix
+// domain socket sock, configured by opts.
+//
+// On Linux, sock may name an abstract socket with a leading "@"
+// instead of a file, e.g. "@mydaemon". It is used unchanged, as are
+// the socks given to the package-level functions. An empty sock is
+// DefaultSocket().
+func NewClient(sock string, opts ...Option) *Client {
+	if sock == "" {
+		sock = DefaultSocket()
+	}
+
+	c := &Client{
+		maxRedirects: defaultMaxRedirects,
+		userAgent:    defaultUserAgent,
+		bulkWorkers:  defaultBulkWorkers,
+		requestID:    newRequestID,
+		dial:         dialSocket,
+		retryRand:    retryRand,
+		jar:          newCookieJar(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// Skipping verification only means something with TLS, and is
+	// done on a copy so the config given WithTLSConfig is untouched.
+	if c.tlsConfig != nil && c.insecureSkipVerify {
+		c.tlsConfig = c.tlsConfig.Clone()
+		c.tlsConfig.InsecureSkipVerify = true
+	}
+
+	// A path the kernel cannot take is reported by every request,
+	// rather than as an obscure dial error.
+	socks := append([]string{sock}, c.failover...)
+	for _, sock := range socks {
+		if c.sockErr = checkSockPath(sock); c.sockErr != nil {
+			break
+		}
+	}
+
+	// Start from the transport given WithTransport, if any, to keep
+	// its connection pool settings.
+	if c.transport != nil {
+		c.transport = c.transport.Clone()
+	} else {
+		c.transport = &http.Transport{}
+	}
+	c.transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		// The default transport protocol for
+		// HTTP clients is TCP, which we can
+		// modify to UDS by creating a new
+		// Unix Domain Socket connection.
+		// Dialing with ctx lets a canceled
+		// request abort the dial as well.
+		conn, err := c.dialFirst(ctx, socks)
+		if err != nil {
+			return nil, err
+		}
+		if c.tlsConfig == nil {
+			return conn, nil
+		}
+
+		// The URL is plain http, so the transport does not
+		// do TLS itself, it is done over the socket here.
+		return handshakeTLS(ctx, conn, c.tlsConfig, c.transport.TLSHandshakeTimeout)
+	}
+	if c.disableKeepAlives {
+		c.transport.DisableKeepAlives = true
+	}
+	c.transport.TLSClientConfig = c.tlsConfig
+	if c.tlsHandshakeTimeout > 0 {
+		c.transport.TLSHandshakeTimeout = c.tlsHandshakeTimeout
+	}
+	var next http.RoundTripper = c.transport
+	if c.roundTripper != nil {
+		next = c.roundTripper
+	}
+	var rt http.RoundTripper = decompressor{next: next}
+	if c.cassette != nil {
+		c.cassette.next = rt
+		rt = c.cassette
+	}
+	c.httpClient = &http.Client{
+		Transport: rt,
+		Timeout:   c.timeout,
+		Jar:       c.jar,
+		// Redirects are followed over the same socket, so a
+		// server redirecting to itself would loop forever
+		// without a limit.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > c.maxRedirects {
+				return ErrTooManyRedirects
+			}
+			return nil
+		},
+	}
+	return c
+}
+
+// Close releases the idle connections of c, and the file descriptors
+// they hold on the socket. Connections still in use by a request are
+// left alone. It always returns nil.
+func (c *Client) Close() error {
+	c.closeIdleConnections()
+	return nil
+}
+
+// closeIdleConnections closes the idle connections of the transport,
+// or of the RoundTripper set WithRoundTripper if it has any.
+func (c *Client) closeIdleConnections() {
+	if c.roundTripper == nil {
+		c.transport.CloseIdleConnections()
+		return
+	}
+	if rt, ok := c.roundTripper.(interface{ CloseIdleConnections() }); ok {
+		rt.CloseIdleConnections()
+	}
+}
+
+// newCookieJar returns the cookie jar of a Client not given one
+// WithCookieJar.
+func newCookieJar() http.CookieJar {
+	// New never fails without options.
+	jar, _ := cookiejar.New(nil)
+	return jar
+}
+
+// oneShotClient returns the Client backing a single call of one of
+// the package-level functions. Nobody reuses its transport, so
+// keep-alives are disabled to close the connection together with the
+// response rather than leave it idle. Create a Client with NewClient
+// to pool connections across calls.
+func oneShotClient(sock string) *Client {
+	return NewClient(sock, WithDisableKeepAlives(true))
+}
+
+// do sends req and records the Stats of its response. Failed
+// attempts are retried as configured WithRetry.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	c.counters.requests.Add(1)
+
+	// Tell a timeout while connecting apart from one waiting for
+	// the response.
+	var conn connTrace
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), conn.clientTrace()))
+
+	resp, err := c.doAttempts(req)
+	err = timeoutError(req.Context(), err, conn.connecting())
+	c.counters.record(resp, err)
+	return resp, err
+}
+
+// doAttempts is do, but for the counting of ClientStats.
+func (c *Client) doAttempts(req *http.Request) (*http.Response, error) {
+	if c.sockErr != nil {
+		return nil, c.sockErr
+	}
+
+	// The base path is prepended once, the attempts share the
+	// URL.
+	c.prependBasePath(req.URL)
+
+	// The ID is set once, so every attempt of the request is
+	// logged under it.
+	if req.Header.Get(requestIDHeader) == "" {
+		req.Header.Set(requestIDHeader, c.requestID())
+	}
+
+	// A server known to be down is spared the request.
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			c.counters.retries.Add(1)
+		}
+		resp, err := c.roundTrip(req)
+		open := c.breaker.done(req, resp, err)
+		if attempt >= c.retryCount || open || !shouldRetry(req, resp, err) {
+			if resp != nil {
+				resp.Body = &timeoutBody{ReadCloser: resp.Body, ctx: req.Context()}
+			}
+			if resp != nil && c.maxResponseBytes > 0 {
+				resp.Body = &limitedBody{ReadCloser: resp.Body, limit: c.maxResponseBytes}
+			}
+			return resp, err
+		}
+
+		// The response is dropped, release its connection.
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		// Wait before the next attempt, as long as the server
+		// asked for if it did, unless the caller gives up in the
+		// meantime.
+		wait := c.retryRand.jitter(backoff)
+		if resp != nil {
+			if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				wait = d
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+
+		req, err = rewind(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// limitedBody is a response body failing with a
+// *ResponseTooLargeError once more than limit bytes are read.
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	n     int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.n > b.limit {
+		return 0, &ResponseTooLargeError{Limit: b.limit}
+	}
+
+	// Reading a byte past the limit is enough to tell the body is
+	// too large.
+	if max := b.limit + 1 - b.n; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if b.n > b.limit {
+		return n - int(b.n-b.limit), &ResponseTooLargeError{Limit: b.limit}
+	}
+	return n, err
+}
+
+// timeoutBody is a response body failing with a
+// *BodyReadTimeoutError when a read times out, i.e. the server went
+// silent after sending the headers until the timeout set WithTimeout
+// or the deadline of the request context.
+type timeoutBody struct {
+	io.ReadCloser
+	ctx context.Context
+	n   int64
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err != nil && err != io.EOF && isTimeout(err) {
+		return n, timeoutError(b.ctx, &BodyReadTimeoutError{Read: b.n, Err: err}, false)
+	}
+	return n, err
+}
+
+// timeoutError returns err as a *TimeoutError of the right kind if it
+// is a timeout of a request bound to ctx, or err unchanged otherwise.
+// connecting tells whether the request was still waiting for a
+// connection.
+func timeoutError(ctx context.Context, err error, connecting bool) error {
+	if err == nil || !isTimeout(err) {
+		return err
+	}
+
+	// The http.Client reports its own timeout the same way as a
+	// deadline of ctx, ask ctx which one passed.
+	var bodyErr *BodyReadTimeoutError
+	kind := TimeoutHeaders
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		kind = TimeoutContext
+	case errors.As(err, &bodyErr):
+		kind = TimeoutBody
+	case connecting:
+		kind = TimeoutDial
+	}
+	return &TimeoutError{Kind: kind, Err: err}
+}
+
+// connTrace follows whether the last attempt of a request got a
+// connection. The TLS handshake, if any, is part of connecting.
+type connTrace struct {
+	state atomic.Int32
+}
+
+const (
+	connIdle int32 = iota
+	connWaiting
+	connGot
+)
+
+func (t *connTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) { t.state.Store(connWaiting) },
+		GotConn: func(httptrace.GotConnInfo) { t.state.Store(connGot) },
+	}
+}
+
+// connecting reports whether the last attempt was still waiting for
+// a connection.
+func (t *connTrace) connecting() bool {
+	return t.state.Load() == connWaiting
+}
+
+// isTimeout reports whether err is a timeout, of the http.Client or of
+// a context deadline.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// roundTrip sends req once and records the Stats of its response.
+func (c *Client) roundTrip(req *http.Request) (*http.Response, error) {
+	if c.preflight {
+		c.probe(req.Context())
+	}
+
+	c.setHeaders(req)
+	if err := c.sign(req); err != nil {
+		return nil, err
+	}
+	c.debug.request(req)
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if c.logger != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		c.logger.Log(req.Method, req.URL.Path, status, time.Since(start), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := c.debug.response(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	stats := Stats{
+		// The transport sets Close when the server answered
+		// with "Connection: close", it then closes the
+		// connection once the body is consumed.
+		ConnectionClosedByServer: resp.Close,
+		ServerTimings:            parseServerTiming(resp.Header),
+		RequestID:                req.Header.Get(requestIDHeader),
+		Header:                   resp.Header.Clone(),
+	}
+	c.mu.Lock()
+	c.lastStats = stats
+	c.mu.Unlock()
+
+	return resp, nil
+}
+
+// setHeaders sets the Host, the User-Agent and the headers configured
+// WithHeader on req. They replace any value already set, so a retried
+// request does not send them twice.
+func (c *Client) setHeaders(req *http.Request) {
+	if c.host != "" {
+		req.Host = c.host
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	for key, values := range c.headers {
+		req.Header[key] = append([]string(nil), values...)
+	}
+}
+
+// prependBasePath prepends the path set WithBasePath, if any, to the
+// path of u.
+func (c *Client) prependBasePath(u *url.URL) {
+	if c.basePath == "" {
+		return
+	}
+	if u.RawPath != "" {
+		u.RawPath = (&url.URL{Path: c.basePath}).EscapedPath() + u.RawPath
+	}
+	u.Path = c.basePath + u.Path
+}
+
+// encodeJSON encodes v as a request body, indented if the Client was
+// created WithPrettyRequests.
+func (c *Client) encodeJSON(v interface{}) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if c.codec != nil {
+		if err := c.marshal(&buf, v); err != nil {
+			return nil, err
+		}
+		return &buf, nil
+	}
+
+	enc := json.NewEncoder(&buf)
+	if c.pretty {
+		enc.SetIndent("", "\t")
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// probe sends HEAD /healthz ahead of a request. Any response proves
+// the pooled connection is alive and it is reused by the request. If
+// the probe fails, the idle connections are dropped so the request
+// dials a fresh one instead.
+//
+// The probe is signed like any request, but skips the cassette, which
+// would answer it without checking the socket.
+func (c *Client) probe(ctx context.Context) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, healthEndpoint.url(), nil)
+	if err != nil {
+		return
+	}
+	c.prependBasePath(req.URL)
+	c.setHeaders(req)
+	if err := c.sign(req); err != nil {
+		return
+	}
+	var rt http.RoundTripper = c.transport
+	if c.roundTripper != nil {
+		rt = c.roundTripper
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		c.closeIdleConnections()
+		return
+	}
+	resp.Body.Close()
+}
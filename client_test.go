@@ -0,0 +1,1012 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newConnCountingServer is like NewUnixDomainSocketServer, but adds
+// one to conns for every connection the server accepts.
+func newConnCountingServer(handler http.Handler, conns *int32) *httptest.Server {
+	ts := &httptest.Server{
+		Listener: listenTempUnix(),
+		Config: &http.Server{
+			Handler: jsonByDefault(handler),
+			ConnState: func(c net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					atomic.AddInt32(conns, 1)
+				}
+			},
+		},
+	}
+	ts.Start()
+
+	return ts
+}
+
+func TestClient(t *testing.T) {
+	t.Run("sequential calls reuse the same connection", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`["Jack"]`))
+		})
+
+		// Create an UDS-based http server that counts every
+		// connection it accepts.
+		var conns int32
+		fakeServer := newConnCountingServer(router, &conns)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		// Calling the function to be tested twice on the same client.
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1])
+		_, err := client.GetUsers()
+		assert.NoError(t, err)
+		_, err = client.GetUsers()
+		assert.NoError(t, err)
+
+		// Both requests should have gone over a single connection.
+		assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+	})
+
+	t.Run("many calls do not leak connections", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`["Jack"]`))
+		})
+		// Failed calls must release their connection as well.
+		router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"msg": "create error"}`))
+		})
+
+		// Create an UDS-based http server that counts every
+		// connection it accepts.
+		var conns int32
+		fakeServer := newConnCountingServer(router, &conns)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1])
+		for i := 0; i < 50; i++ {
+			_, err := client.GetUsers()
+			assert.NoError(t, err)
+			_, err = client.CreateUser("Jack")
+			assert.Error(t, err)
+		}
+
+		// Every response body was closed, so the connection went
+		// back to the pool each time instead of a new one being
+		// dialed per call.
+		assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+	})
+}
+
+func TestWithMaxRedirects(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// Count how many times the endpoint has been requested.
+	var calls int32
+
+	// A misconfigured server that always redirects /api/v1/users
+	// back to /api/v1/users.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Redirect(w, r, "/api/v1/users", http.StatusFound)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// Calling the function to be tested with a lower limit.
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithMaxRedirects(3))
+	_, err := client.GetUsers()
+
+	// The original request plus 3 redirects should have been made.
+	assert.ErrorIs(t, err, ErrTooManyRedirects)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestWithPrettyRequests(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// Keep the raw payload of the last request.
+	var payload string
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		payload = string(body)
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("enabled, the payload is indented", func(t *testing.T) {
+		_, err := NewClient(sock, WithPrettyRequests(true)).CreateUser("Jack")
+		assert.NoError(t, err)
+		assert.Equal(t, "{\n\t\"name\": \"Jack\"\n}\n", payload)
+	})
+
+	t.Run("disabled, the payload is compact", func(t *testing.T) {
+		_, err := NewClient(sock).CreateUser("Jack")
+		assert.NoError(t, err)
+		assert.Equal(t, "{\"name\":\"Jack\"}\n", payload)
+	})
+}
+
+func TestWithPreflightCheck(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// Count the liveness probes.
+	var probes int32
+	router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		atomic.AddInt32(&probes, 1)
+		w.WriteHeader(http.StatusOK)
+	})
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Create an UDS-based http server that counts every
+	// connection it accepts.
+	var conns int32
+	fakeServer := newConnCountingServer(router, &conns)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithPreflightCheck())
+
+	// The first call leaves a connection in the pool.
+	_, err := client.GetUsers()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+
+	// The server drops the idle connection behind the client's back.
+	fakeServer.CloseClientConnections()
+
+	// The probe notices and the request goes over a fresh connection.
+	users, err := client.GetUsers()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Jack"}, users)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&conns))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&probes))
+}
+
+func TestWithPreflightCheckProbe(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// Count the liveness probes, and those not signed.
+	var probes, unsigned int32
+	router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&probes, 1)
+		if r.Header.Get("X-Signature") != signature(r.Method, r.URL.Path, nil) {
+			atomic.AddInt32(&unsigned, 1)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, the probe is signed", func(t *testing.T) {
+		client := NewClient(sock, WithPreflightCheck(), WithRequestSigner(func(req *http.Request, body []byte) error {
+			req.Header.Set("X-Signature", signature(req.Method, req.URL.Path, body))
+			return nil
+		}))
+		before := atomic.LoadInt32(&probes)
+
+		// Calling a function to be tested.
+		_, err := client.GetUsers()
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, before+1, atomic.LoadInt32(&probes))
+		assert.Equal(t, int32(0), atomic.LoadInt32(&unsigned))
+	})
+
+	t.Run("happy path, the probe skips the cassette", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cassette.json")
+		recorder := NewClient(sock, WithPreflightCheck(), WithCassette(path, ModeRecord))
+		_, err := recorder.GetUsers()
+		assert.NoError(t, err)
+		player := NewClient(sock, WithPreflightCheck(), WithCassette(path, ModeReplay))
+		before := atomic.LoadInt32(&probes)
+
+		// Calling a function to be tested.
+		users, err := player.GetUsers()
+
+		// The request is replayed, but the probe reached the socket.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+		assert.Equal(t, before+1, atomic.LoadInt32(&probes))
+	})
+}
+
+func TestWithTimeout(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// A hung server, it answers far later than the client waits.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// Calling the function to be tested with a short timeout.
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithTimeout(50*time.Millisecond))
+	_, err := client.GetUsers()
+
+	// The call gives up with a timeout error.
+	var netErr net.Error
+	assert.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout())
+}
+
+func TestTimeoutPrecedence(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// A hung server, it answers far later than the client waits.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("the Client timeout is shorter than the ctx deadline", func(t *testing.T) {
+		client := NewClient(sock, WithTimeout(50*time.Millisecond))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Calling a function to be tested.
+		start := time.Now()
+		_, err := client.GetUsersContext(ctx)
+
+		// The longer ctx does not extend the Client timeout.
+		var netErr net.Error
+		if assert.ErrorAs(t, err, &netErr) {
+			assert.True(t, netErr.Timeout())
+		}
+		assert.NoError(t, ctx.Err())
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("the ctx deadline is shorter than the Client timeout", func(t *testing.T) {
+		client := NewClient(sock, WithTimeout(10*time.Second))
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// Calling a function to be tested.
+		start := time.Now()
+		_, err := client.GetUsersContext(ctx)
+
+		// The ctx cuts the call short.
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+}
+
+func TestWithAutoUnwrap(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The same user in the different shapes an inconsistent server
+	// sends, keyed by id.
+	bodies := map[string]string{
+		"bare":     `{"id": "ABC-111", "name": "Jack"}`,
+		"array":    `[{"id": "ABC-111", "name": "Jack"}]`,
+		"envelope": `{"data": {"id": "ABC-111", "name": "Jack"}}`,
+	}
+	router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(bodies[strings.TrimPrefix(r.URL.Path, "/api/v1/user/")]))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithAutoUnwrap())
+
+	for shape := range bodies {
+		t.Run(shape, func(t *testing.T) {
+			user, err := client.GetUser(shape)
+			assert.NoError(t, err)
+			assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Name: "Jack"}, user)
+		})
+	}
+}
+
+func TestWithHeader(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// Every request must carry the authorization header.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "bearer xxx", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "bearer xxx", r.Header.Get("Authorization"))
+
+		// The header does not replace the ones set by the call.
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithHeader("Authorization", "bearer xxx"))
+
+	_, err := client.GetUsers()
+	assert.NoError(t, err)
+	_, err = client.CreateUser("Jack")
+	assert.NoError(t, err)
+}
+
+func TestWithMaxRequestBytes(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// Count the requests that reach the server.
+	var calls int32
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`[{"id": "ABC-111", "name": "Jack"}]`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// `[{"name":"Jack"}]` plus the newline of the encoder is 18 bytes.
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithMaxRequestBytes(18))
+
+	t.Run("happy path, a batch within the limit is sent", func(t *testing.T) {
+		_, err := client.CreateUsers([]string{"Jack"})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("unhappy path, an oversized batch is rejected before sending", func(t *testing.T) {
+		_, err := client.CreateUsers([]string{"Jack", "Marry"})
+		assert.ErrorIs(t, err, ErrRequestTooLarge)
+
+		// The server never saw the second batch.
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestWithMaxResponseBytes(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler sends as many users as the q query parameter
+	// asks for, none without it.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("q"))
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(strings.Split(strings.Repeat("Jack,", n), ",")[:n])
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// `["Jack","Jack"]` plus the newline of the encoder is 16 bytes.
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithMaxResponseBytes(16))
+
+	t.Run("happy path, a body within the limit is read", func(t *testing.T) {
+		users, err := client.SearchUsers("2")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack", "Jack"}, users)
+
+		_, _, err = client.GetUsersRaw()
+		assert.NoError(t, err)
+	})
+
+	t.Run("unhappy path, an oversized body is cut short", func(t *testing.T) {
+		_, err := client.SearchUsers("1000")
+
+		var tooLarge *ResponseTooLargeError
+		if assert.ErrorAs(t, err, &tooLarge) {
+			assert.Equal(t, int64(16), tooLarge.Limit)
+		}
+		assert.EqualError(t, err, "response body larger than 16 bytes")
+	})
+
+	t.Run("unhappy path, an oversized body is cut short when read whole", func(t *testing.T) {
+		// `[]` plus the newline of the encoder is 3 bytes.
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithMaxResponseBytes(2))
+
+		_, _, err := client.GetUsersRaw()
+
+		var tooLarge *ResponseTooLargeError
+		assert.ErrorAs(t, err, &tooLarge)
+	})
+}
+
+func TestWithTransport(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	transport := &http.Transport{
+		MaxIdleConnsPerHost: 7,
+		IdleConnTimeout:     time.Minute,
+	}
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithTransport(transport))
+
+	// The pool settings are kept, and requests still go over the
+	// socket.
+	assert.Equal(t, 7, client.transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Minute, client.transport.IdleConnTimeout)
+	_, err := client.GetUsers()
+	assert.NoError(t, err)
+
+	// The given transport is left untouched.
+	assert.Nil(t, transport.DialContext)
+}
+
+// roundTripperFunc is an http.RoundTripper calling itself, counting
+// the calls of CloseIdleConnections.
+type roundTripperFunc struct {
+	fn     func(req *http.Request) (*http.Response, error)
+	closed int
+}
+
+func (f *roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f.fn(req)
+}
+
+func (f *roundTripperFunc) CloseIdleConnections() {
+	f.closed++
+}
+
+func TestWithRoundTripper(t *testing.T) {
+	// Answer with a canned response, no socket is ever dialed.
+	var paths []string
+	rt := &roundTripperFunc{fn: func(req *http.Request) (*http.Response, error) {
+		paths = append(paths, req.Method+" "+req.URL.Path)
+		body := `["Jack", "Marry"]`
+		status := http.StatusOK
+		if req.Method == http.MethodPost {
+			body = `{"id": "ABC-111", "name": "Jack"}`
+			status = http.StatusCreated
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	}}
+	client := NewClient("/nonexistent/dummy.sock", WithRoundTripper(rt))
+
+	// Calling functions to be tested.
+	users, err := client.GetUsers()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Jack", "Marry"}, users)
+
+	user, err := client.CreateUser("Jack")
+	assert.NoError(t, err)
+	assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Name: "Jack"}, user)
+
+	// Test the results of the functions as we expect.
+	assert.Equal(t, []string{"GET /api/v1/users", "POST /api/v1/user"}, paths)
+
+	// Closing the Client closes the idle connections of rt.
+	assert.NoError(t, client.Close())
+	assert.Equal(t, 1, rt.closed)
+}
+
+func TestWithUserAgent(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The User-Agent the server saw last.
+	var userAgent string
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("the configured User-Agent is sent", func(t *testing.T) {
+		client := NewClient(sock, WithUserAgent("my-daemon-client/1.2"))
+
+		_, err := client.GetUsers()
+		assert.NoError(t, err)
+		assert.Equal(t, "my-daemon-client/1.2", userAgent)
+
+		_, err = client.CreateUser("Jack")
+		assert.NoError(t, err)
+		assert.Equal(t, "my-daemon-client/1.2", userAgent)
+	})
+
+	t.Run("the default names this client", func(t *testing.T) {
+		_, err := GetUsers(sock)
+		assert.NoError(t, err)
+		assert.Equal(t, "uds-http-client/"+version, userAgent)
+	})
+}
+
+func TestWithHost(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The Host the server saw last.
+	var host string
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("the configured Host is sent", func(t *testing.T) {
+		client := NewClient(sock, WithHost("users.internal"))
+
+		_, err := client.GetUsers()
+		assert.NoError(t, err)
+		assert.Equal(t, "users.internal", host)
+
+		_, err = client.CreateUser("Jack")
+		assert.NoError(t, err)
+		assert.Equal(t, "users.internal", host)
+	})
+
+	t.Run("the default is the host of the URL", func(t *testing.T) {
+		_, err := GetUsers(sock)
+		assert.NoError(t, err)
+		assert.Equal(t, "_", host)
+	})
+}
+
+func TestClose(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Create an UDS-based http server that tracks how many of the
+	// connections it accepted are still open.
+	var open int32
+	fakeServer := &httptest.Server{
+		Listener: listenTempUnix(),
+		Config: &http.Server{
+			Handler: jsonByDefault(router),
+			ConnState: func(c net.Conn, state http.ConnState) {
+				switch state {
+				case http.StateNew:
+					atomic.AddInt32(&open, 1)
+				case http.StateClosed, http.StateHijacked:
+					atomic.AddInt32(&open, -1)
+				}
+			},
+		},
+	}
+	fakeServer.Start()
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1])
+
+	// The connection stays open in the pool after the call.
+	_, err := client.GetUsers()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&open))
+
+	// Calling a function to be tested.
+	assert.NoError(t, client.Close())
+
+	// The server sees the idle connection go away.
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&open) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWithDisableKeepAlives(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Create an UDS-based http server that tracks how many of the
+	// connections it accepted are still open.
+	var open int32
+	fakeServer := &httptest.Server{
+		Listener: listenTempUnix(),
+		Config: &http.Server{
+			Handler: jsonByDefault(router),
+			ConnState: func(c net.Conn, state http.ConnState) {
+				switch state {
+				case http.StateNew:
+					atomic.AddInt32(&open, 1)
+				case http.StateClosed, http.StateHijacked:
+					atomic.AddInt32(&open, -1)
+				}
+			},
+		},
+	}
+	fakeServer.Start()
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	// Keep-alives are on by default.
+	assert.False(t, NewClient(sock).transport.DisableKeepAlives)
+
+	client := NewClient(sock, WithDisableKeepAlives(true))
+	assert.True(t, client.transport.DisableKeepAlives)
+
+	// Calling a function to be tested.
+	_, err := client.GetUsers()
+	assert.NoError(t, err)
+
+	// The server sees the connection go away without Close.
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&open) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWithBasePath(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler records the path of every request.
+	var paths []string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		switch r.Method {
+		case http.MethodGet:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`["Jack"]`))
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+		}
+	}
+	router.HandleFunc("/service-a/api/v1/users", record)
+	router.HandleFunc("/service-a/api/v1/user", record)
+	router.HandleFunc("/api/v1/users", record)
+	router.HandleFunc("/service-a/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id": "a/b", "name": "Jack"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	for _, basePath := range []string{"/service-a/", "/service-a", "service-a"} {
+		t.Run("happy path, base path "+basePath, func(t *testing.T) {
+			paths = nil
+			client := NewClient(sock, WithBasePath(basePath))
+
+			// Calling functions to be tested.
+			_, err := client.GetUsers()
+			assert.NoError(t, err)
+			_, err = client.CreateUser("Jack")
+			assert.NoError(t, err)
+
+			// The server sees the full paths.
+			assert.Equal(t, []string{"/service-a/api/v1/users", "/service-a/api/v1/user"}, paths)
+		})
+	}
+
+	t.Run("happy path, escaped ids keep their escaping", func(t *testing.T) {
+		paths = nil
+		client := NewClient(sock, WithBasePath("/service-a"))
+
+		// Calling a function to be tested.
+		_, err := client.GetUser("a/b")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/service-a/api/v1/user/a%2Fb"}, paths)
+	})
+
+	for _, basePath := range []string{"", "/"} {
+		t.Run("happy path, empty base path "+basePath, func(t *testing.T) {
+			paths = nil
+			client := NewClient(sock, WithBasePath(basePath))
+
+			_, err := client.GetUsers()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"/api/v1/users"}, paths)
+		})
+	}
+}
+
+func TestWithDialContext(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, the dial func is called per connection", func(t *testing.T) {
+		// The dial func counts its calls, then dials as usual.
+		var dials int32
+		dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			assert.Equal(t, "unix", network)
+			assert.Equal(t, sock, addr)
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		}
+		client := NewClient(sock, WithDialContext(dial))
+
+		// Calling the function to be tested twice on the same
+		// client.
+		for i := 0; i < 2; i++ {
+			users, err := client.GetUsers()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"Jack"}, users)
+		}
+
+		// The connection was reused.
+		assert.Equal(t, int32(1), atomic.LoadInt32(&dials))
+	})
+
+	t.Run("unhappy path, the dial func fails", func(t *testing.T) {
+		errInjected := errors.New("injected fault")
+		dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errInjected
+		}
+
+		_, err := NewClient(sock, WithDialContext(dial)).GetUsers()
+
+		var connErr *ConnectError
+		assert.ErrorAs(t, err, &connErr)
+		assert.ErrorIs(t, err, errInjected)
+	})
+}
+
+func TestWithContentType(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler echoes the user back, from a form or from json,
+	// like the fake_server does, and rejects any other Content-Type
+	// it receives.
+	var (
+		mu                sync.Mutex
+		contentType, body string
+	)
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		mu.Lock()
+		contentType, body = r.Header.Get("Content-Type"), string(b)
+		mu.Unlock()
+
+		var user CreateUserResponse
+		switch r.Header.Get("Content-Type") {
+		case "application/x-www-form-urlencoded":
+			form, err := url.ParseQuery(string(b))
+			assert.NoError(t, err)
+			user = CreateUserResponse{Name: form.Get("name"), Email: form.Get("email")}
+		case "application/json":
+			assert.NoError(t, json.Unmarshal(b, &user))
+		default:
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		user.ID = "id_foo"
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(user)
+	})
+
+	// Create an UDS-based http server and register the router as is,
+	// so no helper sets a Content-Type in place of the handler.
+	fakeServer := &httptest.Server{
+		Listener: listenTempUnix(),
+		Config:   &http.Server{Handler: router},
+	}
+	fakeServer.Start()
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	// received returns the Content-Type and the body the server got
+	// last.
+	received := func() (string, string) {
+		mu.Lock()
+		defer mu.Unlock()
+		return contentType, body
+	}
+
+	t.Run("happy path, json by default", func(t *testing.T) {
+		user, err := NewClient(sock).CreateUser("Jack")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "id_foo", Name: "Jack"}, user)
+		gotType, gotBody := received()
+		assert.Equal(t, "application/json", gotType)
+		assert.JSONEq(t, `{"name": "Jack"}`, gotBody)
+	})
+
+	t.Run("happy path, form", func(t *testing.T) {
+		client := NewClient(sock, WithContentType(ContentTypeForm))
+
+		// Calling functions to be tested.
+		user, err := client.CreateUser("Jack")
+
+		// Test the results of the functions as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "id_foo", Name: "Jack"}, user)
+		gotType, gotBody := received()
+		assert.Equal(t, "application/x-www-form-urlencoded", gotType)
+		assert.Equal(t, "name=Jack", gotBody)
+
+		// The fields not set are left out.
+		user, err = client.CreateUserWithParams(CreateUserParams{Name: "Jack & Jill", Email: "jack@example.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "id_foo", Name: "Jack & Jill", Email: "jack@example.com"}, user)
+		_, gotBody = received()
+		assert.Equal(t, "email=jack%40example.com&name=Jack+%26+Jill", gotBody)
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Codec marshals the request bodies and unmarshals the response bodies
+// of a Client set WithCodec, e.g. with a faster JSON library than
+// encoding/json, or one remapping field names.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// WithCodec makes the Client encode and decode the bodies with codec
+// instead of encoding/json. Bodies read as they stream in are still
+// split by encoding/json, which hands each of them whole to codec.
+// Error bodies are always decoded by encoding/json.
+func WithCodec(codec Codec) Option {
+	return func(c *Client) {
+		c.codec = codec
+	}
+}
+
+// codecValue decodes into v with codec.
+type codecValue struct {
+	codec Codec
+	v     interface{}
+}
+
+func (cv *codecValue) UnmarshalJSON(b []byte) error {
+	return cv.codec.Unmarshal(b, cv.v)
+}
+
+// decodeTarget returns what to decode a body into for it to end up in
+// v, using the Codec of c, if any.
+func (c *Client) decodeTarget(v interface{}) interface{} {
+	if c.codec == nil || v == nil {
+		return v
+	}
+	return &codecValue{codec: c.codec, v: v}
+}
+
+// unmarshal decodes data into v with the Codec of c, if any.
+func (c *Client) unmarshal(data []byte, v interface{}) error {
+	if c.codec == nil {
+		return json.Unmarshal(data, v)
+	}
+	return c.codec.Unmarshal(data, v)
+}
+
+// marshal encodes v with the Codec of c, appending the result to buf,
+// indented if the Client was created WithPrettyRequests.
+func (c *Client) marshal(buf *bytes.Buffer, v interface{}) error {
+	b, err := c.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if c.pretty {
+		return json.Indent(buf, b, "", "\t")
+	}
+	_, err = buf.Write(b)
+	return err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingCodec is a Codec using encoding/json, counting its calls.
+type countingCodec struct {
+	marshals   int32
+	unmarshals int32
+}
+
+func (c *countingCodec) Marshal(v interface{}) ([]byte, error) {
+	atomic.AddInt32(&c.marshals, 1)
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	atomic.AddInt32(&c.unmarshals, 1)
+	return json.Unmarshal(data, v)
+}
+
+func TestWithCodec(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack", "Marry"]`))
+	})
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		// The request body is the output of the codec.
+		var req CreateUserRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "Jack", req.Name)
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+	})
+	router.HandleFunc("/api/v1/user/id_foo", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"msg": "user not found"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	codec := &countingCodec{}
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithCodec(codec))
+
+	t.Run("GetUsers decodes with the codec", func(t *testing.T) {
+		users, err := client.GetUsers()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack", "Marry"}, users)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&codec.marshals))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&codec.unmarshals))
+	})
+
+	t.Run("CreateUser encodes and decodes with the codec", func(t *testing.T) {
+		user, err := client.CreateUser("Jack")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "id_foo", Name: "Jack"}, user)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&codec.marshals))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&codec.unmarshals))
+	})
+
+	t.Run("error bodies are not decoded with the codec", func(t *testing.T) {
+		_, err := client.GetUser("id_foo")
+
+		assert.ErrorIs(t, err, ErrUserNotFound)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&codec.unmarshals))
+	})
+}
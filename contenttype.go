@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// ErrUnexpectedContentType is wrapped by the error returned when a
+// successful response to be decoded is not JSON, e.g. the HTML page of
+// a misconfigured proxy, which would otherwise fail with a misleading
+// JSON syntax error.
+var ErrUnexpectedContentType = errors.New("unexpected content type")
+
+// WithLenientContentType makes the Client decode successful responses
+// whatever their Content-Type, for servers not sending
+// application/json.
+func WithLenientContentType() Option {
+	return func(c *Client) {
+		c.lenientContentType = true
+	}
+}
+
+// ContentType is how CreateUser encodes the user it sends.
+type ContentType int
+
+const (
+	// ContentTypeJSON sends the user as application/json, the
+	// default.
+	ContentTypeJSON ContentType = iota
+
+	// ContentTypeForm sends the user as
+	// application/x-www-form-urlencoded, e.g. name=Jack, for older
+	// servers. The response is still JSON.
+	ContentTypeForm
+)
+
+// WithContentType makes CreateUser send the user encoded as ct.
+func WithContentType(ct ContentType) Option {
+	return func(c *Client) {
+		c.contentType = ct
+	}
+}
+
+// checkContentType returns an error wrapping ErrUnexpectedContentType
+// if resp is a success of e to be decoded into out, but is not
+// application/json. Parameters such as the charset are allowed.
+func (c *Client) checkContentType(e endpoint, resp *http.Response, out interface{}) error {
+	if c.lenientContentType || out == nil || !e.succeeded(resp.StatusCode) {
+		return nil
+	}
+	return checkJSONContentType(resp)
+}
+
+// checkJSONContentType returns an error wrapping
+// ErrUnexpectedContentType if resp is not application/json.
+func checkJSONContentType(resp *http.Response) error {
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "application/json" {
+		return fmt.Errorf("%w %q", ErrUnexpectedContentType, contentType)
+	}
+	return nil
+}
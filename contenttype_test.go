@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentTypeCheck(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The Content-Type and the status of the server, changed by each
+	// subtest.
+	var (
+		contentType string
+		status      int
+	)
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, JSON with a charset", func(t *testing.T) {
+		contentType, status = "application/json; charset=utf-8", http.StatusOK
+
+		users, err := GetUsers(sock)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+	})
+
+	t.Run("unhappy path, HTML", func(t *testing.T) {
+		contentType, status = "text/html", http.StatusOK
+
+		_, err := GetUsers(sock)
+
+		assert.ErrorIs(t, err, ErrUnexpectedContentType)
+		assert.EqualError(t, err, `unexpected content type "text/html"`)
+	})
+
+	t.Run("unhappy path, HTML read whole", func(t *testing.T) {
+		contentType, status = "text/html", http.StatusOK
+
+		_, raw, err := GetUsersRaw(sock)
+
+		// The body is still returned.
+		assert.ErrorIs(t, err, ErrUnexpectedContentType)
+		assert.Equal(t, `["Jack"]`, string(raw))
+	})
+
+	t.Run("unhappy path, HTML streamed", func(t *testing.T) {
+		contentType, status = "text/html", http.StatusOK
+
+		names, errc := GetUsersChan(context.Background(), sock)
+
+		// No name is delivered.
+		_, ok := <-names
+		assert.False(t, ok)
+		assert.ErrorIs(t, <-errc, ErrUnexpectedContentType)
+	})
+
+	t.Run("unhappy path, an HTML error is still an *APIError", func(t *testing.T) {
+		contentType, status = "text/html", http.StatusInternalServerError
+
+		_, err := GetUsers(sock)
+
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+	})
+
+	t.Run("happy path, HTML with WithLenientContentType", func(t *testing.T) {
+		contentType, status = "text/html", http.StatusOK
+
+		users, err := NewClient(sock, WithLenientContentType()).GetUsers()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+)
+
+// redactedHeaders are the headers never written in clear by
+// WithDebugWriter.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// WithDebugWriter writes every request sent and response received to
+// w as they are on the wire: the method, path, headers and body. The
+// values of the Authorization, Proxy-Authorization, Cookie and
+// Set-Cookie headers, as well as of the redact headers, are replaced
+// with "REDACTED".
+//
+// Only the first 4 KiB of each response body are written, read ahead
+// of the caller, so it is meant for troubleshooting only. The rest of
+// the body is still read as it streams in, within the limit set
+// WithMaxResponseBytes, if any.
+func WithDebugWriter(w io.Writer, redact ...string) Option {
+	return func(c *Client) {
+		d := &debugTap{w: w, redact: make(map[string]bool)}
+		for _, key := range append(redactedHeaders, redact...) {
+			d.redact[http.CanonicalHeaderKey(key)] = true
+		}
+		c.debug = d
+	}
+}
+
+// debugTap writes the requests and responses of a Client to w. A nil
+// *debugTap writes nothing.
+type debugTap struct {
+	w      io.Writer
+	redact map[string]bool
+
+	// mu keeps the dumps of concurrent requests from interleaving.
+	mu sync.Mutex
+}
+
+// redacted returns a copy of h with the values of the sensitive
+// headers replaced.
+func (d *debugTap) redacted(h http.Header) http.Header {
+	h = h.Clone()
+	for key := range h {
+		if d.redact[key] {
+			h[key] = []string{"REDACTED"}
+		}
+	}
+	return h
+}
+
+// request writes req, leaving its body untouched.
+func (d *debugTap) request(req *http.Request) {
+	if d == nil {
+		return
+	}
+
+	// The body is read from a copy, it is still to be sent. A body
+	// that cannot be copied is left out.
+	dump := req.Clone(req.Context())
+	dump.Header = d.redacted(req.Header)
+	body := req.GetBody != nil
+	if body {
+		var err error
+		if dump.Body, err = req.GetBody(); err != nil {
+			body = false
+		}
+	}
+	if !body {
+		dump.Body = nil
+	}
+	b, err := httputil.DumpRequestOut(dump, body)
+	if err != nil {
+		return
+	}
+	d.write(b)
+}
+
+// maxDebugBodyLen is how much of a response body WithDebugWriter
+// writes.
+const maxDebugBodyLen = 4 << 10
+
+// response writes resp, with the start of its body, which is read
+// ahead and put back in front of the rest.
+func (d *debugTap) response(resp *http.Response) error {
+	if d == nil {
+		return nil
+	}
+
+	// DumpResponse writes resp.Header, swap the redacted one in.
+	header := resp.Header
+	resp.Header = d.redacted(header)
+	b, err := httputil.DumpResponse(resp, false)
+	resp.Header = header
+	if err != nil {
+		return err
+	}
+
+	// A byte more than written tells whether the body is cut. A
+	// failed read is left to the caller, once past the prefix.
+	prefix, err := io.ReadAll(io.LimitReader(resp.Body, maxDebugBodyLen+1))
+	rest := io.Reader(resp.Body)
+	if err != nil {
+		rest = failedReader{err: err}
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), rest), resp.Body}
+	if len(prefix) > maxDebugBodyLen {
+		b = append(b, prefix[:maxDebugBodyLen]...)
+		b = append(b, "\n[body truncated]"...)
+	} else {
+		b = append(b, prefix...)
+	}
+	d.write(b)
+	return nil
+}
+
+// failedReader fails every read with err.
+type failedReader struct {
+	err error
+}
+
+func (r failedReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func (d *debugTap) write(b []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.w.Write(b)
+	d.w.Write([]byte("\n"))
+}
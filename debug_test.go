@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithDebugWriter(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		// The body is still sent once written.
+		var body bytes.Buffer
+		body.ReadFrom(r.Body)
+		assert.JSONEq(t, `{"name": "Jack"}`, body.String())
+		assert.Equal(t, "bearer xxx", r.Header.Get("Authorization"))
+
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "ABC-111", "name": "Jack"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	var buf bytes.Buffer
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1],
+		WithHeader("Authorization", "bearer xxx"),
+		WithHeader("X-Api-Key", "key"),
+		WithDebugWriter(&buf, "x-api-key"),
+	)
+	defer client.Close()
+
+	// Calling a function to be tested.
+	user, err := client.CreateUser("Jack")
+
+	// Test the results of the function as we expect.
+	assert.NoError(t, err)
+	assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Name: "Jack"}, user)
+
+	dump := buf.String()
+	assert.Contains(t, dump, "POST /api/v1/user HTTP/1.1\r\n")
+	assert.Contains(t, dump, "Content-Type: application/json\r\n")
+	assert.Contains(t, dump, `{"name":"Jack"}`)
+	assert.Contains(t, dump, "HTTP/1.1 201 Created\r\n")
+	assert.Contains(t, dump, `{"id": "ABC-111", "name": "Jack"}`)
+
+	// The sensitive headers are redacted, the others kept.
+	assert.Contains(t, dump, "Authorization: REDACTED\r\n")
+	assert.Contains(t, dump, "X-Api-Key: REDACTED\r\n")
+	assert.Contains(t, dump, "Set-Cookie: REDACTED\r\n")
+	assert.NotContains(t, dump, "bearer xxx")
+	assert.NotContains(t, dump, "session=secret")
+	assert.Contains(t, dump, "User-Agent: "+defaultUserAgent+"\r\n")
+}
+
+func TestWithDebugWriterMaxResponseBytes(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The server sends users without end, until the client hangs up.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[`))
+		for r.Context().Err() == nil {
+			if _, err := w.Write([]byte(`"Jack",`)); err != nil {
+				return
+			}
+		}
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	var buf bytes.Buffer
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1],
+		WithDebugWriter(&buf),
+		WithMaxResponseBytes(64<<10),
+	)
+	defer client.Close()
+
+	// Calling a function to be tested.
+	_, err := client.GetUsers()
+
+	// The limit still applies, and only the start of the body is
+	// written.
+	var tooLarge *ResponseTooLargeError
+	assert.ErrorAs(t, err, &tooLarge)
+	dump := buf.String()
+	assert.Contains(t, dump, "HTTP/1.1 200 OK\r\n")
+	assert.Contains(t, dump, `["Jack","Jack",`)
+	assert.Contains(t, dump, "[body truncated]")
+	assert.Less(t, len(dump), 2*maxDebugBodyLen)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"net"
+)
+
+// dialSocket connects to the unix domain socket sock. On Linux, a sock
+// starting with "@" is an abstract socket, which has no file.
+func dialSocket(ctx context.Context, sock string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "unix", sock)
+}
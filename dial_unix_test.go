@@ -0,0 +1,54 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialSocket(t *testing.T) {
+	t.Run("happy path, socket file", func(t *testing.T) {
+		l := listenTempUnix()
+		defer l.Close()
+
+		conn, err := dialSocket(context.Background(), l.Addr().String())
+		if assert.NoError(t, err) {
+			assert.Equal(t, "unix", conn.RemoteAddr().Network())
+			conn.Close()
+		}
+	})
+
+	t.Run("happy path, abstract socket", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("abstract sockets only exist on Linux")
+		}
+
+		// Abstract names are shared by the whole machine, so make it
+		// unique to this process.
+		sock := fmt.Sprintf("@golang-uds-http-client-test-dial-%d", os.Getpid())
+		l, err := net.Listen("unix", sock)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer l.Close()
+
+		conn, err := dialSocket(context.Background(), sock)
+		if assert.NoError(t, err) {
+			conn.Close()
+		}
+	})
+
+	t.Run("unhappy path, no socket", func(t *testing.T) {
+		_, err := dialSocket(context.Background(), filepath.Join(t.TempDir(), "missing.sock"))
+
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
+
+// ErrNamedPipeUnsupported is returned when dialing a Windows named
+// pipe. Sharing one between the reads and the writes of a connection
+// needs overlapped I/O, which the standard library does not offer for
+// pipes, e.g. github.com/Microsoft/go-winio does.
+var ErrNamedPipeUnsupported = errors.New("named pipes are not supported")
+
+// pipePrefix starts the path of every named pipe.
+const pipePrefix = `\\.\pipe\`
+
+// dialSocket connects to the unix domain socket sock, supported since
+// Windows 10. Named pipes are told apart by their \\.\pipe\ prefix.
+func dialSocket(ctx context.Context, sock string) (net.Conn, error) {
+	if strings.HasPrefix(strings.ToLower(sock), pipePrefix) {
+		return nil, ErrNamedPipeUnsupported
+	}
+
+	var d net.Dialer
+	return d.DialContext(ctx, "unix", sock)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialSocket(t *testing.T) {
+	t.Run("happy path, socket file", func(t *testing.T) {
+		sock := filepath.Join(t.TempDir(), "dummy.sock")
+		l, err := net.Listen("unix", sock)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer l.Close()
+
+		conn, err := dialSocket(context.Background(), sock)
+		if assert.NoError(t, err) {
+			conn.Close()
+		}
+	})
+
+	t.Run("unhappy path, named pipe", func(t *testing.T) {
+		_, err := dialSocket(context.Background(), `\\.\pipe\uds-http-client`)
+
+		assert.ErrorIs(t, err, ErrNamedPipeUnsupported)
+	})
+
+	t.Run("unhappy path, named pipe through the client", func(t *testing.T) {
+		_, err := GetUsers(`\\.\PIPE\uds-http-client`)
+
+		var connErr *ConnectError
+		assert.ErrorAs(t, err, &connErr)
+		assert.ErrorIs(t, err, ErrNamedPipeUnsupported)
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// Do sends a method request to path, e.g. "/api/v2/users?limit=10",
+// to reach an endpoint that has no method of its own. A non-nil body
+// is sent as json. A 2xx response body is decoded into out, unless
+// out is nil; any other status is returned as an *APIError, as by
+// the other methods. The status code is returned as well, or 0 if no
+// response was received.
+func (c *Client) Do(ctx context.Context, method, path string, body io.Reader, out interface{}) (int, error) {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	e := endpoint{
+		method: method,
+		path:   path,
+		accept: func(status int) bool {
+			return status >= 200 && status < 300
+		},
+	}
+
+	// Keep the payload nil without a body, an interface holding a
+	// nil io.Reader is not.
+	var payload interface{}
+	if body != nil {
+		payload = body
+	}
+
+	resp, err := c.sendResponse(ctx, e, e.url(), payload, out)
+	if resp == nil {
+		return 0, err
+	}
+	return resp.StatusCode, err
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// An endpoint the client has no method for, echoing the query.
+	router.HandleFunc("/api/v2/users", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"limit": "` + r.URL.Query().Get("limit") + `"}`))
+	})
+
+	// An endpoint echoing the name of the json body it is sent.
+	router.HandleFunc("/api/v2/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var req CreateUserRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"msg": "invalid body"}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"id": "ABC-111", "name": "` + req.Name + `"}`))
+	})
+
+	// An endpoint answering without a body.
+	router.HandleFunc("/api/v2/cache", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1])
+	defer client.Close()
+
+	t.Run("happy path, GET a custom path with a query", func(t *testing.T) {
+		// Calling a function to be tested.
+		var out struct {
+			Limit string `json:"limit"`
+		}
+		status, err := client.Do(context.Background(), http.MethodGet, "/api/v2/users?limit=10", nil, &out)
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "10", out.Limit)
+	})
+
+	t.Run("happy path, POST a json body", func(t *testing.T) {
+		var out CreateUserResponse
+		status, err := client.Do(context.Background(), http.MethodPost, "api/v2/user", strings.NewReader(`{"name": "Jack"}`), &out)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, status)
+		assert.Equal(t, CreateUserResponse{ID: "ABC-111", Name: "Jack"}, out)
+	})
+
+	t.Run("happy path, no body is decoded into a nil out", func(t *testing.T) {
+		status, err := client.Do(context.Background(), http.MethodDelete, "/api/v2/cache", nil, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, status)
+	})
+
+	t.Run("unhappy path, the server rejects the request", func(t *testing.T) {
+		status, err := client.Do(context.Background(), http.MethodPost, "/api/v2/user", strings.NewReader(`not json`), nil)
+
+		assert.Equal(t, http.StatusBadRequest, status)
+		var apiErr *APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+			assert.Equal(t, "invalid body", apiErr.Msg)
+		}
+	})
+
+	t.Run("unhappy path, the path does not exist", func(t *testing.T) {
+		status, err := client.Do(context.Background(), http.MethodGet, "/api/v2/nothing", nil, nil)
+
+		assert.Equal(t, http.StatusNotFound, status)
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+	})
+
+	t.Run("unhappy path, the body cannot be read", func(t *testing.T) {
+		status, err := client.Do(context.Background(), http.MethodPost, "/api/v2/user", io.MultiReader(strings.NewReader("{"), errReader{}), nil)
+
+		assert.Equal(t, 0, status)
+		assert.ErrorIs(t, err, errRead)
+	})
+
+	t.Run("unhappy path, an endless body is rejected", func(t *testing.T) {
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithMaxRequestBytes(1024))
+
+		status, err := client.Do(context.Background(), http.MethodPost, "/api/v2/user", endlessReader{}, nil)
+
+		// The body is read no further than the limit.
+		assert.Equal(t, 0, status)
+		assert.ErrorIs(t, err, ErrRequestTooLarge)
+	})
+
+	t.Run("unhappy path, no server listens", func(t *testing.T) {
+		status, err := NewClient("/nonexistent/dummy.sock").Do(context.Background(), http.MethodGet, "/", nil, nil)
+
+		assert.Equal(t, 0, status)
+		var connErr *ConnectError
+		assert.ErrorAs(t, err, &connErr)
+	})
+}
+
+// errRead is the error of every read from an errReader.
+var errRead = errors.New("read failed")
+
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, errRead
+}
+
+// endlessReader never runs out of bytes.
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = ' '
+	}
+	return len(p), nil
+}
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// errorMsgFields are the fields of a JSON error body that may carry
+// the message, in the order they are tried. The API server uses
+// "msg", other servers or proxies in front of it the others.
+var errorMsgFields = []string{"msg", "error", "message"}
+
+// maxErrorBodyLen is how much of a non-JSON error body is kept in
+// the message of an APIError.
+const maxErrorBodyLen = 512
+
+// newAPIError creates the APIError of a failed response. The message
+// is the first non-empty string of errorMsgFields in the body, or the
+// body itself when there is none, e.g. an HTML page of a proxy in
+// front of the server.
+func newAPIError(status int, body []byte) *APIError {
+	if msg := errorMsg(body); msg != "" {
+		return &APIError{StatusCode: status, Msg: msg}
+	}
+
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		msg = http.StatusText(status)
+	}
+	if len(msg) > maxErrorBodyLen {
+		msg = strings.ToValidUTF8(msg[:maxErrorBodyLen], "") + "..."
+	}
+	return &APIError{StatusCode: status, Msg: msg}
+}
+
+// errorMsg returns the first non-empty string of errorMsgFields in the
+// JSON error body, or "" if there is none.
+func errorMsg(body []byte) string {
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(body, &data); err != nil {
+		return ""
+	}
+	for _, field := range errorMsgFields {
+		var msg string
+		if json.Unmarshal(data[field], &msg) == nil && msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// fieldErrors returns the errors by field of a 400 body shaped as
+//
+//	{
+//		"errors": {"name": "too long"}
+//	}
+//
+// or nil if it is not.
+func fieldErrors(body []byte) map[string]string {
+	var data struct {
+		Errors map[string]string `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil || len(data.Errors) == 0 {
+		return nil
+	}
+	return data.Errors
+}
+
+// responseError is like newAPIError for the failed resp, also
+// carrying the request ID it was sent with.
+func responseError(resp *http.Response, body []byte) *APIError {
+	apiErr := newAPIError(resp.StatusCode, body)
+	if resp.Request != nil {
+		apiErr.RequestID = resp.Request.Header.Get(requestIDHeader)
+	}
+	return apiErr
+}
+
+// endpoint describes an API endpoint and which response statuses
+// count as a success for it.
+type endpoint struct {
+	method string
+	path   string
+
+	// status is the single status code expected on success.
+	status int
+
+	// accept, if set, decides whether a status code is a success
+	// and takes precedence over status.
+	accept func(status int) bool
+
+	// notFound, if set, is wrapped by the *APIError of a 404
+	// response.
+	notFound error
+
+	// emptyOK, if set, lets a success come with an empty body,
+	// leaving the value to decode into untouched.
+	emptyOK bool
+}
+
+var (
+	getUsersEndpoint = endpoint{
+		method: http.MethodGet,
+		path:   "/api/v1/users",
+		status: http.StatusOK,
+	}
+	getUserListEndpoint = endpoint{
+		method: http.MethodGet,
+		path:   "/api/v1/users/full",
+		status: http.StatusOK,
+	}
+	// createUserEndpoint may answer with only the Location of
+	// the user.
+	createUserEndpoint = endpoint{
+		method:  http.MethodPost,
+		path:    "/api/v1/user",
+		status:  http.StatusCreated,
+		emptyOK: true,
+	}
+	// createUsersEndpoint answers 207 Multi-Status when only some
+	// of the users were created.
+	createUsersEndpoint = endpoint{
+		method: http.MethodPost,
+		path:   "/api/v1/users",
+		accept: func(status int) bool {
+			return status == http.StatusCreated || status == http.StatusMultiStatus
+		},
+	}
+	getUsersByIDsEndpoint = endpoint{
+		method: http.MethodGet,
+		path:   "/api/v1/users/by-id",
+		status: http.StatusOK,
+	}
+	getUserEndpoint = endpoint{
+		method:   http.MethodGet,
+		path:     "/api/v1/user",
+		status:   http.StatusOK,
+		notFound: ErrUserNotFound,
+	}
+	updateUserEndpoint = endpoint{
+		method:   http.MethodPut,
+		path:     "/api/v1/user",
+		status:   http.StatusOK,
+		notFound: ErrUserNotFound,
+	}
+	patchUserEndpoint = endpoint{
+		method:   http.MethodPatch,
+		path:     "/api/v1/user",
+		status:   http.StatusOK,
+		notFound: ErrUserNotFound,
+	}
+	deleteUserEndpoint = endpoint{
+		method:   http.MethodDelete,
+		path:     "/api/v1/user",
+		status:   http.StatusNoContent,
+		notFound: ErrUserNotFound,
+	}
+	// loginEndpoint answers with the session cookie.
+	loginEndpoint = endpoint{
+		method: http.MethodPost,
+		path:   "/api/v1/login",
+		accept: func(status int) bool {
+			return status == http.StatusOK || status == http.StatusNoContent
+		},
+	}
+	healthEndpoint = endpoint{
+		method: http.MethodGet,
+		path:   "/healthz",
+		status: http.StatusOK,
+	}
+	// metricsEndpoint is requested with the path given by the
+	// caller, any 2xx response carries the metrics.
+	metricsEndpoint = endpoint{
+		method: http.MethodGet,
+		accept: func(status int) bool {
+			return status >= 200 && status < 300
+		},
+	}
+)
+
+// succeeded reports whether status is a success for the endpoint.
+func (e endpoint) succeeded(status int) bool {
+	if e.accept != nil {
+		return e.accept(status)
+	}
+	return status == e.status
+}
+
+// url returns the URL of the endpoint, with elems appended to its
+// path as escaped path segments.
+// For UDS-based HTTP, the domain in the URL
+// is not important and is ignored here with
+// an underscore (_).
+func (e endpoint) url(elems ...string) string {
+	u := "http://_" + e.path
+	for _, elem := range elems {
+		u += "/" + url.PathEscape(elem)
+	}
+	return u
+}
+
+// handleResponse reads the response of a request made to e and
+// returns the raw body along with the outcome. On success the body is
+// decoded into out, otherwise an *APIError carrying the status and
+// the "msg" of the error body is returned.
+func handleResponse(e endpoint, resp *http.Response, out interface{}) ([]byte, error) {
+	// Reading and parsing the response body.
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.succeeded(resp.StatusCode) {
+		// The body may have been cut short by the server, which
+		// it tells in a trailer, known once the body is read.
+		if err := streamError(resp); err != nil {
+			return body, err
+		}
+
+		// If the request is successful,
+		// decode the result, if any is wanted.
+		if out == nil {
+			return body, nil
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return body, &DecodeError{Body: body, Err: err}
+		}
+		return body, nil
+	}
+
+	// If it fails, return the "msg" in the
+	// response body.
+	apiErr := responseError(resp, body)
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		apiErr.err = e.notFound
+	case http.StatusBadRequest:
+		if fields := fieldErrors(body); fields != nil {
+			// The fields tell what is wrong, the body itself
+			// is not worth repeating.
+			if errorMsg(body) == "" {
+				apiErr.Msg = "validation failed"
+			}
+			return body, &ValidationError{APIError: apiErr, Fields: fields}
+		}
+	case http.StatusPreconditionFailed:
+		apiErr.err = ErrPreconditionFailed
+	case http.StatusTooManyRequests:
+		retryAfter, _ := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return body, &RateLimitError{APIError: apiErr, RetryAfter: retryAfter}
+	}
+	return body, apiErr
+}
+
+// decodeResponse is like handleResponse, but on success the body is
+// decoded into out as it is read, instead of being read whole first.
+// This spares a copy of long bodies, e.g. of thousands of users.
+func decodeResponse(e endpoint, resp *http.Response, out interface{}) error {
+	if !e.succeeded(resp.StatusCode) {
+		// The error body is short, read it to get the "msg".
+		_, err := handleResponse(e, resp, nil)
+		return err
+	}
+
+	var err error
+	if out != nil {
+		// Keep the start of the body for the DecodeError, if any.
+		// A failed read is not the fault of the body, it is
+		// returned as is.
+		r := &recordingReader{r: resp.Body, max: maxDecodeErrorBodyLen}
+		err = json.NewDecoder(r).Decode(out)
+		if err != nil && r.err == nil {
+			err = &DecodeError{Body: r.buf, Err: err}
+		}
+	}
+
+	// Drain what the decoder leaves behind, so the connection can
+	// be reused and the trailers are received.
+	io.Copy(io.Discard, resp.Body)
+
+	// A body cut short by the server likely fails to decode too,
+	// but the trailer tells why.
+	if streamErr := streamError(resp); streamErr != nil {
+		return streamErr
+	}
+	return err
+}
+
+// maxDecodeErrorBodyLen is how much of a body decoded as it is read
+// is kept in a DecodeError.
+const maxDecodeErrorBodyLen = 64 << 10
+
+// recordingReader reads from r, keeping the first max bytes read and
+// the error of the read that failed, if any, other than io.EOF.
+type recordingReader struct {
+	r   io.Reader
+	max int
+	buf []byte
+	err error
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if room := r.max - len(r.buf); room > 0 {
+		if n < room {
+			room = n
+		}
+		r.buf = append(r.buf, p[:room]...)
+	}
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// streamError returns a *StreamError if the server reported in the
+// streamErrorTrailer of resp that the body is incomplete. It must be
+// called after the body was read to the end.
+func streamError(resp *http.Response) error {
+	if msg := resp.Trailer.Get(streamErrorTrailer); msg != "" {
+		return &StreamError{Msg: msg}
+	}
+	return nil
+}
+
+// send sends a request to target of e. A non-nil payload is sent as
+// the json body and the response is decoded into out, which may be
+// nil for endpoints answering without a body.
+func (c *Client) send(ctx context.Context, e endpoint, target string, payload, out interface{}, opts ...requestOption) error {
+	_, err := c.sendResponse(ctx, e, target, payload, out, opts...)
+	return err
+}
+
+// sendResponse is like send, but also returns the response, if one
+// was received, for its status and headers. Its body is closed.
+func (c *Client) sendResponse(ctx context.Context, e endpoint, target string, payload, out interface{}, opts ...requestOption) (*http.Response, error) {
+	start := time.Now()
+	resp, err := c.request(ctx, e, target, payload, opts...)
+	if err != nil {
+		c.observe(e, target, nil, start)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// There is nothing to check nor decode.
+	if e.emptyOK && e.succeeded(resp.StatusCode) && isEmptyBody(resp) {
+		c.observe(e, target, resp, start)
+		return resp, nil
+	}
+
+	if err := c.checkContentType(e, resp, out); err != nil {
+		c.observe(e, target, resp, start)
+		return resp, err
+	}
+
+	// Decoding the response body as it streams in.
+	err = decodeResponse(e, resp, c.decodeTarget(out))
+	c.observe(e, target, resp, start)
+	return resp, err
+}
+
+// isEmptyBody reports whether the body of resp is empty. The first
+// byte is peeked at, and kept for the next read of the body.
+func isEmptyBody(resp *http.Response) bool {
+	r := bufio.NewReader(resp.Body)
+	_, err := r.Peek(1)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{r, resp.Body}
+	return err == io.EOF
+}
+
+// sendRaw is like send, but also returns the raw response body, even
+// when decoding it fails.
+func (c *Client) sendRaw(ctx context.Context, e endpoint, target string, payload, out interface{}, opts ...requestOption) ([]byte, error) {
+	start := time.Now()
+	resp, err := c.request(ctx, e, target, payload, opts...)
+	if err != nil {
+		c.observe(e, target, nil, start)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// The body is not decoded, but still returned.
+	if err := c.checkContentType(e, resp, out); err != nil {
+		body, _ := io.ReadAll(resp.Body)
+		c.observe(e, target, resp, start)
+		return body, err
+	}
+
+	// Reading and parsing the response body.
+	body, err := handleResponse(e, resp, c.decodeTarget(out))
+	c.observe(e, target, resp, start)
+	return body, err
+}
+
+// requestOption sets a setting of a single request on it before it
+// is sent, e.g. a header only this request carries.
+type requestOption func(req *http.Request)
+
+// withRequestHeader returns a requestOption setting the header key to
+// value.
+func withRequestHeader(key, value string) requestOption {
+	return func(req *http.Request) {
+		req.Header.Set(key, value)
+	}
+}
+
+// request sends a request to target of e, with payload, if not nil,
+// as the body: a form for url.Values, the bytes read for an
+// io.Reader, json otherwise. opts are applied to the request before
+// it is sent. The caller must close the response body.
+func (c *Client) request(ctx context.Context, e endpoint, target string, payload interface{}, opts ...requestOption) (*http.Response, error) {
+	var (
+		body        io.Reader
+		contentType string
+	)
+	if payload != nil {
+		var buf *bytes.Buffer
+		switch payload := payload.(type) {
+		case url.Values:
+			// Encode the payload as a form.
+			buf = bytes.NewBufferString(payload.Encode())
+			contentType = "application/x-www-form-urlencoded"
+		case io.Reader:
+			// Read the payload whole, so the request body
+			// can be rewound for a retry, but no more than
+			// a byte past the limit, so an endless payload
+			// is rejected too. It is expected to be json.
+			r := payload
+			if c.maxRequestBytes > 0 {
+				r = io.LimitReader(payload, c.maxRequestBytes+1)
+			}
+			buf = &bytes.Buffer{}
+			if _, err := buf.ReadFrom(r); err != nil {
+				return nil, err
+			}
+			if c.maxRequestBytes > 0 && int64(buf.Len()) > c.maxRequestBytes {
+				return nil, fmt.Errorf("%w: more than %d bytes", ErrRequestTooLarge, c.maxRequestBytes)
+			}
+			contentType = "application/json"
+		default:
+			// Encode the payload into json format.
+			var err error
+			buf, err = c.encodeJSON(payload)
+			if err != nil {
+				return nil, err
+			}
+			contentType = "application/json"
+		}
+		if c.maxRequestBytes > 0 && int64(buf.Len()) > c.maxRequestBytes {
+			return nil, fmt.Errorf("%w: %d bytes, the limit is %d", ErrRequestTooLarge, buf.Len(), c.maxRequestBytes)
+		}
+		body = buf
+	}
+
+	// Create a new http request bound to ctx, so canceling
+	// ctx aborts both the dial and the round trip.
+	req, err := http.NewRequestWithContext(ctx, e.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Add("Content-Type", contentType)
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	// Send the http request to the server.
+	return c.do(req)
+}
+
+// unwrapEnvelope returns the object wrapped in a single-element array
+// or in a {"data": {...}} envelope, or raw itself if it is neither.
+func unwrapEnvelope(raw json.RawMessage) json.RawMessage {
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err == nil && len(list) == 1 {
+		return list[0]
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err == nil && len(envelope.Data) > 0 && envelope.Data[0] == '{' {
+		return envelope.Data
+	}
+	return raw
+}
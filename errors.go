@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// APIError is returned when the server answers with a status that
+// is not a success for the endpoint.
+type APIError struct {
+	// StatusCode is the http status code of the response.
+	StatusCode int
+
+	// Msg is the message of the error body, e.g. its "msg", or the
+	// (truncated) body itself if it has none.
+	Msg string
+
+	// RequestID is the X-Request-ID the request was sent with, if
+	// known, to find the request in the logs of the server.
+	RequestID string
+
+	// err is the sentinel the status means for the endpoint, e.g.
+	// ErrUserNotFound, if any.
+	err error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api error (%d): %s", e.StatusCode, e.Msg)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// DecodeError is returned when the server answered with a success,
+// but the body could not be decoded, e.g. it is not JSON or a field
+// has an unexpected type. Unlike an *APIError, sending the request
+// again is unlikely to help.
+type DecodeError struct {
+	// Body is the response body, or its first 64 KiB when it was
+	// decoded as it was read.
+	Body []byte
+
+	// Err is the JSON error.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("invalid response body: %v", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ResponseTooLargeError is returned when a response body is larger
+// than allowed WithMaxResponseBytes. Only the allowed bytes are read.
+type ResponseTooLargeError struct {
+	// Limit is the most bytes a body may have.
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body larger than %d bytes", e.Limit)
+}
+
+// BodyReadTimeoutError is returned when the server sent the headers
+// of a response, but then stalled while sending the body until the
+// timeout set WithTimeout, or the deadline of the context, passed.
+// Unlike a timeout while dialing or waiting for the headers, the
+// server got the request and started answering it. It is returned
+// wrapped in a *TimeoutError.
+type BodyReadTimeoutError struct {
+	// Read is how many bytes of the body were received.
+	Read int64
+
+	// Err is the timeout error of the read.
+	Err error
+}
+
+func (e *BodyReadTimeoutError) Error() string {
+	return fmt.Sprintf("response body timed out after %d bytes: %v", e.Read, e.Err)
+}
+
+func (e *BodyReadTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutKind tells which deadline a *TimeoutError hit.
+type TimeoutKind int
+
+const (
+	// TimeoutDial is the timeout set WithTimeout passing while
+	// connecting to the socket. The request was not sent.
+	TimeoutDial TimeoutKind = iota
+
+	// TimeoutHeaders is the timeout set WithTimeout passing while
+	// waiting for the headers of the response. The server may or
+	// may not have handled the request.
+	TimeoutHeaders
+
+	// TimeoutBody is the timeout set WithTimeout passing while
+	// reading the body of the response. The server handled the
+	// request.
+	TimeoutBody
+
+	// TimeoutContext is the deadline of the context of the request
+	// passing, at any stage.
+	TimeoutContext
+)
+
+func (k TimeoutKind) String() string {
+	switch k {
+	case TimeoutDial:
+		return "dial"
+	case TimeoutHeaders:
+		return "headers"
+	case TimeoutBody:
+		return "body"
+	case TimeoutContext:
+		return "context"
+	}
+	return "unknown"
+}
+
+// TimeoutError is returned when a request times out, telling the
+// deadline of the caller, which retrying the request cannot help,
+// apart from the timeouts of the Client. It wraps the original error,
+// e.g. a *ConnectError, a *BodyReadTimeoutError or
+// context.DeadlineExceeded.
+type TimeoutError struct {
+	// Kind is the deadline that passed.
+	Kind TimeoutKind
+
+	// Err is the timeout error.
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%v timeout: %v", e.Kind, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// RateLimitError is returned when the server answers 429 Too Many
+// Requests. It wraps the *APIError of the response.
+type RateLimitError struct {
+	*APIError
+
+	// RetryAfter is how long the server asked to wait before
+	// trying again, from its Retry-After header. It is 0 if the
+	// header was not sent.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%v (retry after %v)", e.APIError, e.RetryAfter)
+	}
+	return e.APIError.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.APIError
+}
+
+// ValidationError is returned when the server answers 400 Bad Request
+// telling which fields of the request are invalid:
+//
+//	{
+//		"msg": "invalid user",
+//		"errors": {"name": "too long"}
+//	}
+//
+// It wraps the *APIError of the response. A 400 without the "errors"
+// is returned as a plain *APIError.
+type ValidationError struct {
+	*APIError
+
+	// Fields is the error of each invalid field, by field name.
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field, msg := range e.Fields {
+		fields = append(fields, field+": "+msg)
+	}
+	sort.Strings(fields)
+	return fmt.Sprintf("%v (%s)", e.APIError, strings.Join(fields, ", "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.APIError
+}
+
+// streamErrorTrailer is the trailer the server sets when it could
+// not send the whole body of a streamed response, e.g. because
+// reading the users failed halfway.
+const streamErrorTrailer = "X-Stream-Error"
+
+// StreamError is returned when the server answered with a success,
+// but reported in the X-Stream-Error trailer that the body is
+// incomplete.
+type StreamError struct {
+	// Msg is the value of the trailer.
+	Msg string
+}
+
+func (e *StreamError) Error() string {
+	return "incomplete response: " + e.Msg
+}
+
+// ConnectError is returned when the connection to the socket cannot
+// be established, e.g. the socket file does not exist or the server
+// is not running. No request reached the server then.
+type ConnectError struct {
+	// Sock is the socket that was dialed.
+	Sock string
+
+	// Err is the dial error.
+	Err error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("connect to %s: %v", e.Sock, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError is returned by CreateUsers when the server created only
+// some of the users.
+type BatchError struct {
+	// Failures holds each user that was not created, in the order
+	// of the request.
+	Failures []BatchFailure
+}
+
+// BatchFailure is a user of a batch that was not created.
+type BatchFailure struct {
+	Name string
+	Err  *APIError
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		msgs[i] = fmt.Sprintf("%q: %v", f.Name, f.Err)
+	}
+	return "users not created: " + strings.Join(msgs, "; ")
+}
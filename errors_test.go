@@ -0,0 +1,693 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIError(t *testing.T) {
+	t.Run("renders the status and the message", func(t *testing.T) {
+		err := &APIError{StatusCode: http.StatusInternalServerError, Msg: "get error"}
+		assert.EqualError(t, err, "api error (500): get error")
+	})
+
+	t.Run("can be found in a wrapped error", func(t *testing.T) {
+		err := fmt.Errorf("sync users: %w", &APIError{StatusCode: http.StatusServiceUnavailable, Msg: "busy"})
+
+		var apiErr *APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	})
+
+	t.Run("callers can branch on the status code", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// Each endpoint fails with a different status.
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"msg": "no users"}`))
+		})
+		router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"msg": "bad name"}`))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		var apiErr *APIError
+
+		_, err := GetUsers(sock)
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+			assert.Equal(t, "no users", apiErr.Msg)
+		}
+
+		_, err = CreateUser(sock, "Jack")
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+			assert.Equal(t, "bad name", apiErr.Msg)
+		}
+	})
+}
+
+func TestNonJSONErrorBody(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// A proxy in front of the server answers with an HTML page...
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html><body>502 Bad Gateway</body></html>\n"))
+	})
+	// ...or with no body at all.
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("HTML body", func(t *testing.T) {
+		_, err := GetUsers(sock)
+		assert.EqualError(t, err, "api error (502): <html><body>502 Bad Gateway</body></html>")
+	})
+
+	t.Run("empty body", func(t *testing.T) {
+		_, err := CreateUser(sock, "Jack")
+		assert.EqualError(t, err, "api error (503): Service Unavailable")
+	})
+
+	t.Run("long body is truncated", func(t *testing.T) {
+		err := newAPIError(http.StatusBadGateway, []byte(strings.Repeat("x", 1000)))
+		assert.Equal(t, strings.Repeat("x", maxErrorBodyLen)+"...", err.Msg)
+	})
+}
+
+func TestAPIErrorMessageFields(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		msg  string
+	}{
+		{"happy path, msg", `{"msg": "bad name"}`, "bad name"},
+		{"happy path, error", `{"error": "bad name"}`, "bad name"},
+		{"happy path, message", `{"message": "bad name"}`, "bad name"},
+		{"happy path, msg comes first", `{"message": "from message", "error": "from error", "msg": "from msg"}`, "from msg"},
+		{"happy path, error comes before message", `{"message": "from message", "error": "from error"}`, "from error"},
+		{"happy path, an empty field is skipped", `{"msg": "", "message": "from message"}`, "from message"},
+		{"happy path, a field that is not a string is skipped", `{"error": {"code": 7}, "message": "from message"}`, "from message"},
+		{"unhappy path, no known field", `{"detail": "bad name"}`, `{"detail": "bad name"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Calling a function to be tested.
+			err := newAPIError(http.StatusBadRequest, []byte(tt.body))
+
+			// Test the results of the function as we expect.
+			assert.Equal(t, http.StatusBadRequest, err.StatusCode)
+			assert.Equal(t, tt.msg, err.Msg)
+		})
+	}
+
+	t.Run("happy path, through a call", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "unauthorized", "message": "token expired"}`))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		_, err := GetUsers(strings.Split(fakeServer.URL, "//")[1])
+
+		assert.EqualError(t, err, "api error (401): unauthorized")
+	})
+}
+
+func TestValidationError(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The body of the 400 is the name of the user to create.
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		var req CreateUserRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(req.Name))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, field errors with a msg", func(t *testing.T) {
+		// Calling a function to be tested.
+		_, err := CreateUser(sock, `{"msg": "invalid user", "errors": {"name": "too long", "email": "invalid"}}`)
+
+		// Test the results of the function as we expect.
+		var validationErr *ValidationError
+		if assert.ErrorAs(t, err, &validationErr) {
+			assert.Equal(t, http.StatusBadRequest, validationErr.StatusCode)
+			assert.Equal(t, "invalid user", validationErr.Msg)
+			assert.Equal(t, map[string]string{"name": "too long", "email": "invalid"}, validationErr.Fields)
+		}
+		assert.EqualError(t, err, "api error (400): invalid user (email: invalid, name: too long)")
+
+		// It is an *APIError as well.
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+	})
+
+	t.Run("happy path, field errors alone", func(t *testing.T) {
+		_, err := CreateUser(sock, `{"errors": {"name": "too long"}}`)
+
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.EqualError(t, err, "api error (400): validation failed (name: too long)")
+	})
+
+	t.Run("unhappy path, a flat msg", func(t *testing.T) {
+		_, err := CreateUser(sock, `{"msg": "name is required"}`)
+
+		var validationErr *ValidationError
+		assert.False(t, errors.As(err, &validationErr))
+		assert.EqualError(t, err, "api error (400): name is required")
+	})
+
+	t.Run("unhappy path, errors of another shape", func(t *testing.T) {
+		_, err := CreateUser(sock, `{"msg": "invalid user", "errors": ["name is too long"]}`)
+
+		var validationErr *ValidationError
+		assert.False(t, errors.As(err, &validationErr))
+		assert.EqualError(t, err, "api error (400): invalid user")
+	})
+}
+
+func TestBodyReadTimeoutError(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// Send the headers and half of the users, then stall until the
+	// client gives up.
+	const partial = `["Jack", "Ma`
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(partial))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("unhappy path, the client timeout passes", func(t *testing.T) {
+		client := NewClient(sock, WithTimeout(100*time.Millisecond))
+
+		// Calling a function to be tested.
+		start := time.Now()
+		_, err := client.GetUsers()
+
+		// Test the results of the function as we expect.
+		var timeoutErr *BodyReadTimeoutError
+		if assert.ErrorAs(t, err, &timeoutErr) {
+			assert.Equal(t, int64(len(partial)), timeoutErr.Read)
+		}
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("unhappy path, the context deadline passes", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		// Calling a function to be tested.
+		_, err := NewClient(sock).GetUsersContext(ctx)
+
+		// Test the results of the function as we expect.
+		var timeoutErr *BodyReadTimeoutError
+		if assert.ErrorAs(t, err, &timeoutErr) {
+			assert.Equal(t, int64(len(partial)), timeoutErr.Read)
+		}
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("unhappy path, the headers never come", func(t *testing.T) {
+		// Accept connections, but never answer.
+		l := listenTempUnix()
+		defer l.Close()
+		client := NewClient(l.Addr().String(), WithTimeout(100*time.Millisecond))
+
+		_, err := client.GetUsers()
+
+		// It is a timeout, but not of the body.
+		var timeoutErr *BodyReadTimeoutError
+		assert.Error(t, err)
+		assert.False(t, errors.As(err, &timeoutErr))
+	})
+}
+
+func TestConnectError(t *testing.T) {
+	t.Run("the socket file does not exist", func(t *testing.T) {
+		// Calling functions to be tested.
+		_, getErr := GetUsers("missing.sock")
+		_, createErr := CreateUser("missing.sock", "Jack")
+
+		// Both fail before reaching a server.
+		for _, err := range []error{getErr, createErr} {
+			var connErr *ConnectError
+			if assert.ErrorAs(t, err, &connErr) {
+				assert.Equal(t, "missing.sock", connErr.Sock)
+			}
+
+			// It is not mistaken for an answer of the server.
+			var apiErr *APIError
+			assert.False(t, errors.As(err, &apiErr))
+		}
+	})
+
+	t.Run("a response is not a connect error", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"msg": "get error"}`))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		_, err := GetUsers(strings.Split(fakeServer.URL, "//")[1])
+
+		var connErr *ConnectError
+		assert.False(t, errors.As(err, &connErr))
+	})
+}
+
+func TestErrUserNotFound(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// Every user is unknown to the server.
+	router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"msg": "user not found"}`))
+	})
+	// Other endpoints may answer 404 for other reasons.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"msg": "no such endpoint"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("GetUser, UpdateUser and DeleteUser report the unknown user", func(t *testing.T) {
+		_, getErr := GetUser(sock, "ABC-111")
+		_, updateErr := UpdateUser(sock, "ABC-111", "Jack")
+		deleteErr := DeleteUser(sock, "ABC-111")
+
+		for _, err := range []error{getErr, updateErr, deleteErr} {
+			assert.True(t, errors.Is(err, ErrUserNotFound))
+
+			// It is still the *APIError of the response.
+			var apiErr *APIError
+			if assert.ErrorAs(t, err, &apiErr) {
+				assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+			}
+			assert.EqualError(t, err, "api error (404): user not found")
+		}
+	})
+
+	t.Run("a 404 of another endpoint is not about a user", func(t *testing.T) {
+		_, err := GetUsers(sock)
+		assert.False(t, errors.Is(err, ErrUserNotFound))
+	})
+}
+
+func TestRateLimitError(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The Retry-After of the server, changed by each subtest.
+	var retryAfter string
+	rateLimited := func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Retry-After", retryAfter)
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"msg": "slow down"}`))
+	}
+	router.HandleFunc("/api/v1/users", rateLimited)
+	router.HandleFunc("/api/v1/user", rateLimited)
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("Retry-After in seconds", func(t *testing.T) {
+		retryAfter = "120"
+
+		_, err := GetUsers(sock)
+
+		var rateErr *RateLimitError
+		if assert.ErrorAs(t, err, &rateErr) {
+			assert.Equal(t, 2*time.Minute, rateErr.RetryAfter)
+		}
+		assert.EqualError(t, err, "api error (429): slow down (retry after 2m0s)")
+
+		// It is still the *APIError of the response.
+		var apiErr *APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+		}
+	})
+
+	t.Run("Retry-After as an HTTP date", func(t *testing.T) {
+		retryAfter = time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+
+		_, err := CreateUser(sock, "Jack")
+
+		// The date has a precision of a second.
+		var rateErr *RateLimitError
+		if assert.ErrorAs(t, err, &rateErr) {
+			assert.InDelta(t, time.Minute, rateErr.RetryAfter, float64(time.Second))
+		}
+	})
+
+	t.Run("malformed Retry-After", func(t *testing.T) {
+		retryAfter = "soon"
+
+		_, err := GetUsers(sock)
+
+		var rateErr *RateLimitError
+		if assert.ErrorAs(t, err, &rateErr) {
+			assert.Zero(t, rateErr.RetryAfter)
+		}
+	})
+}
+
+func TestStreamError(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler streams the users in chunks, then sets the
+	// X-Stream-Error trailer to streamErr, changed by each subtest.
+	var streamErr string
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Stream-Error")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"`))
+		for i := 0; i < 1000; i++ {
+			// Flushing sends each chunk on its own.
+			w.(http.Flusher).Flush()
+			fmt.Fprintf(w, `,"user_%d"`, i)
+		}
+		w.Write([]byte(`]`))
+		w.Header().Set("X-Stream-Error", streamErr)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, the whole chunked body is read", func(t *testing.T) {
+		streamErr = ""
+
+		users, err := GetUsers(sock)
+
+		assert.NoError(t, err)
+		if assert.Len(t, users, 1001) {
+			assert.Equal(t, "Jack", users[0])
+			assert.Equal(t, "user_999", users[1000])
+		}
+	})
+
+	t.Run("unhappy path, the trailer reports an incomplete stream", func(t *testing.T) {
+		streamErr = "database went away"
+
+		_, err := GetUsers(sock)
+
+		var sErr *StreamError
+		if assert.ErrorAs(t, err, &sErr) {
+			assert.Equal(t, "database went away", sErr.Msg)
+		}
+		assert.EqualError(t, err, "incomplete response: database went away")
+	})
+
+	t.Run("unhappy path, the trailer reports an incomplete stream of the raw body", func(t *testing.T) {
+		streamErr = "database went away"
+
+		_, body, err := GetUsersRaw(sock)
+
+		var sErr *StreamError
+		assert.ErrorAs(t, err, &sErr)
+
+		// The body read so far is still returned.
+		assert.True(t, strings.HasPrefix(string(body), `["Jack"`))
+	})
+}
+
+func TestDecodeError(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The status and the body of the server, changed by each subtest.
+	var (
+		status int
+		body   string
+	)
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+
+	// The server promises more than it sends, so reading the body
+	// fails halfway.
+	router.HandleFunc("/api/v1/user/id_foo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id": "id_foo"`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("unhappy path, a success body that does not decode", func(t *testing.T) {
+		status, body = http.StatusOK, `["Jack", 42]`
+
+		_, err := GetUsers(sock)
+
+		var decodeErr *DecodeError
+		if assert.ErrorAs(t, err, &decodeErr) {
+			assert.Equal(t, body, string(decodeErr.Body))
+		}
+		var apiErr *APIError
+		assert.False(t, errors.As(err, &apiErr))
+		assert.True(t, strings.HasPrefix(err.Error(), "invalid response body: json: cannot unmarshal number"))
+	})
+
+	t.Run("unhappy path, a raw success body that does not decode", func(t *testing.T) {
+		status, body = http.StatusOK, `not json`
+
+		_, _, err := GetUsersRaw(sock)
+
+		var decodeErr *DecodeError
+		if assert.ErrorAs(t, err, &decodeErr) {
+			assert.Equal(t, body, string(decodeErr.Body))
+		}
+	})
+
+	t.Run("unhappy path, an error body that does not decode", func(t *testing.T) {
+		status, body = http.StatusInternalServerError, `not json`
+
+		_, err := GetUsers(sock)
+
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		var decodeErr *DecodeError
+		assert.False(t, errors.As(err, &decodeErr))
+	})
+
+	t.Run("unhappy path, reading the body fails", func(t *testing.T) {
+		_, err := GetUser(sock, "id_foo")
+
+		// It is not the body that is wrong.
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		var decodeErr *DecodeError
+		assert.False(t, errors.As(err, &decodeErr))
+	})
+}
+
+func TestTimeoutError(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// A hung server, it answers far later than the client waits.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Send the headers, then stall until the client gives up.
+	router.HandleFunc("/api/v1/users/full", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[`))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("unhappy path, the context deadline passes", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// Calling a function to be tested.
+		_, err := NewClient(sock, WithTimeout(10*time.Second)).GetUsersContext(ctx)
+
+		// Test the results of the function as we expect.
+		var timeoutErr *TimeoutError
+		if assert.ErrorAs(t, err, &timeoutErr) {
+			assert.Equal(t, TimeoutContext, timeoutErr.Kind)
+		}
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("unhappy path, the headers come too late", func(t *testing.T) {
+		client := NewClient(sock, WithTimeout(50*time.Millisecond))
+
+		// Calling a function to be tested.
+		_, err := client.GetUsers()
+
+		// Test the results of the function as we expect.
+		var timeoutErr *TimeoutError
+		if assert.ErrorAs(t, err, &timeoutErr) {
+			assert.Equal(t, TimeoutHeaders, timeoutErr.Kind)
+		}
+	})
+
+	t.Run("unhappy path, the body stalls", func(t *testing.T) {
+		client := NewClient(sock, WithTimeout(100*time.Millisecond))
+
+		// Calling a function to be tested.
+		_, err := client.GetUserList()
+
+		// Test the results of the function as we expect.
+		var timeoutErr *TimeoutError
+		if assert.ErrorAs(t, err, &timeoutErr) {
+			assert.Equal(t, TimeoutBody, timeoutErr.Kind)
+		}
+		var bodyErr *BodyReadTimeoutError
+		assert.ErrorAs(t, err, &bodyErr)
+	})
+
+	t.Run("unhappy path, the dial hangs", func(t *testing.T) {
+		// The dial only returns once it is given up.
+		client := NewClient(sock,
+			WithTimeout(50*time.Millisecond),
+			WithDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}),
+		)
+
+		// Calling a function to be tested.
+		_, err := client.GetUsers()
+
+		// Test the results of the function as we expect.
+		var timeoutErr *TimeoutError
+		if assert.ErrorAs(t, err, &timeoutErr) {
+			assert.Equal(t, TimeoutDial, timeoutErr.Kind)
+		}
+	})
+
+	t.Run("happy path, other errors are left alone", func(t *testing.T) {
+		err := errors.New("boom")
+		assert.Equal(t, err, timeoutError(context.Background(), err, true))
+	})
+}
@@ -0,0 +1,8 @@
+package main
+
+import "errors"
+
+// ErrPreconditionFailed is wrapped by the *APIError returned when the
+// server answers 412 Precondition Failed, e.g. to UpdateUserIfMatch
+// with the ETag of a user that was modified since.
+var ErrPreconditionFailed = errors.New("precondition failed")
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"net"
+)
+
+// WithFailover makes the Client fall back to socks, in order, when the
+// socket given to NewClient cannot be dialed, e.g. the standbys of a
+// daemon listening on several sockets. Every new connection starts
+// over from the first socket, so the Client returns to the primary
+// once it is back. Only dial failures move on to the next socket, an
+// error answered by a server is returned as is. If all the sockets
+// fail, the *ConnectError of the last one is returned.
+func WithFailover(socks ...string) Option {
+	return func(c *Client) {
+		c.failover = append([]string(nil), socks...)
+	}
+}
+
+// dialFirst returns a connection to the first of socks that can be
+// dialed, or the *ConnectError of the last one.
+func (c *Client) dialFirst(ctx context.Context, socks []string) (net.Conn, error) {
+	var err error
+	for _, sock := range socks {
+		var conn net.Conn
+		conn, err = c.dial(ctx, sock)
+		if err == nil {
+			return conn, nil
+		}
+		err = &ConnectError{Sock: sock, Err: err}
+
+		// The other sockets would fail the same way.
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithFailover(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+	router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"msg": "user not found"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler. It stands for the standby.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	standby := strings.Split(fakeServer.URL, "//")[1]
+
+	// The primary is down, its socket file is absent.
+	primary := filepath.Join(t.TempDir(), "primary.sock")
+
+	t.Run("happy path, the standby answers", func(t *testing.T) {
+		client := NewClient(primary, WithFailover(standby))
+		defer client.Close()
+
+		// Calling a function to be tested.
+		users, err := client.GetUsers()
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+	})
+
+	t.Run("happy path, an error of the server does not fail over", func(t *testing.T) {
+		// The standby is first, the primary would not answer.
+		client := NewClient(standby, WithFailover(primary))
+		defer client.Close()
+
+		_, err := client.GetUser("XYZ-999")
+
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+
+	t.Run("unhappy path, all the sockets are down", func(t *testing.T) {
+		other := filepath.Join(t.TempDir(), "other.sock")
+		client := NewClient(primary, WithFailover(other))
+		defer client.Close()
+
+		_, err := client.GetUsers()
+
+		// The error of the last socket is returned.
+		var connErr *ConnectError
+		if assert.ErrorAs(t, err, &connErr) {
+			assert.Equal(t, other, connErr.Sock)
+		}
+	})
+
+	t.Run("unhappy path, a standby path is too long", func(t *testing.T) {
+		client := NewClient(standby, WithFailover("/tmp/"+strings.Repeat("x", maxSockPathLen())))
+
+		_, err := client.GetUsers()
+
+		assert.ErrorIs(t, err, ErrSockPathTooLong)
+	})
+}
@@ -1,27 +1,417 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// defaultSock is the socket the server listens on when neither the
+// -sock flag nor the UDS_SOCK environment variable is set.
+const defaultSock = "mysock.sock"
+
+// maxNameLen is the longest name, in bytes, a user may be created
+// with.
+const maxNameLen = 32
+
+// shutdownTimeout bounds how long the server waits for the requests in
+// flight when it is stopped.
+const shutdownTimeout = 5 * time.Second
+
 func main() {
-	os.Remove("mysock.sock")
+	// The socket path comes from the -sock flag, then from the
+	// UDS_SOCK environment variable.
+	sock := os.Getenv("UDS_SOCK")
+	if sock == "" {
+		sock = defaultSock
+	}
+	flag.StringVar(&sock, "sock", sock, "path of the unix domain socket to listen on")
+	flag.Parse()
+
+	// Stop serving on Ctrl-C or kill, so the socket file is removed
+	// instead of blocking the next start.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, sock, newRouter()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves handler on the unix domain socket sock until ctx is
+// done, then shuts the server down and removes the socket file.
+func run(ctx context.Context, sock string, handler http.Handler) error {
+	os.Remove(sock)
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sock)
+
+	srv := &http.Server{Handler: handler}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Let the requests in flight finish, but not forever.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// newRouter returns the handler of the fake API server.
+func newRouter() *gin.Engine {
 	r := gin.Default()
+	r.GET("/healthz", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{
+			"status": "ok",
+		})
+	})
+	r.POST("/api/v1/login", func(ctx *gin.Context) {
+		var req struct {
+			User     string `json:"user"`
+			Password string `json:"password"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+		if req.User != "jack" || req.Password != "secret" {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"msg": "wrong user or password",
+			})
+			return
+		}
+
+		// The session is not checked by the other endpoints, the
+		// cookie only has to be unique.
+		session := strconv.FormatInt(time.Now().UnixNano(), 36)
+		ctx.SetCookie("session", session, 0, "/", "", false, true)
+		ctx.Status(http.StatusNoContent)
+	})
 	r.GET("/api/v1/users", func(ctx *gin.Context) {
-		ctx.JSON(http.StatusOK, []string{
+		users := []string{
 			"Jack",
 			"Marry",
 			"Sandy",
+		}
+
+		// Only keep the users whose name contains the query, if
+		// any.
+		if q := strings.ToLower(ctx.Query("q")); q != "" {
+			var matched []string
+			for _, user := range users {
+				if strings.Contains(strings.ToLower(user), q) {
+					matched = append(matched, user)
+				}
+			}
+			users = matched
+		}
+
+		// Only keep the users whose name starts with the prefix,
+		// if any.
+		if prefix := ctx.Query("prefix"); prefix != "" {
+			matched := []string{}
+			for _, user := range users {
+				if strings.HasPrefix(user, prefix) {
+					matched = append(matched, user)
+				}
+			}
+			users = matched
+		}
+
+		// Users can only be sorted by name.
+		if sortBy := ctx.Query("sort"); sortBy != "" {
+			if sortBy != "name" {
+				ctx.JSON(http.StatusBadRequest, gin.H{
+					"msg": "cannot sort by " + sortBy,
+				})
+				return
+			}
+			switch ctx.DefaultQuery("order", "asc") {
+			case "asc":
+				sort.Strings(users)
+			case "desc":
+				sort.Sort(sort.Reverse(sort.StringSlice(users)))
+			default:
+				ctx.JSON(http.StatusBadRequest, gin.H{
+					"msg": "invalid order",
+				})
+				return
+			}
+		}
+
+		// Without paging parameters, answer with the bare list for
+		// older clients.
+		if ctx.Query("page") == "" {
+			ctx.JSON(http.StatusOK, users)
+			return
+		}
+
+		page, err := strconv.Atoi(ctx.Query("page"))
+		if err != nil || page < 1 {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"msg": "invalid page",
+			})
+			return
+		}
+		pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+		if err != nil || pageSize < 1 {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"msg": "invalid page_size",
+			})
+			return
+		}
+
+		start := (page - 1) * pageSize
+		if start > len(users) {
+			start = len(users)
+		}
+		end := start + pageSize
+		if end > len(users) {
+			end = len(users)
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"users": users[start:end],
+			"total": len(users),
 		})
 	})
+	// The users created with an Idempotency-Key, by key.
+	var created struct {
+		sync.Mutex
+		byKey map[string]gin.H
+	}
+	created.byKey = map[string]gin.H{}
 	r.POST("/api/v1/user", func(ctx *gin.Context) {
-		ctx.JSON(http.StatusCreated, gin.H{
+		var req struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+			Role  string `json:"role"`
+		}
+
+		// Older clients send the user as a form.
+		if ctx.ContentType() == "application/x-www-form-urlencoded" {
+			req.Name = ctx.PostForm("name")
+			req.Email = ctx.PostForm("email")
+			req.Role = ctx.PostForm("role")
+		} else if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		// Tell which fields are invalid, if any.
+		fields := gin.H{}
+		if len(req.Name) > maxNameLen {
+			fields["name"] = "too long"
+		}
+		if req.Email != "" && !strings.Contains(req.Email, "@") {
+			fields["email"] = "invalid"
+		}
+		if len(fields) > 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"msg":    "invalid user",
+				"errors": fields,
+			})
+			return
+		}
+
+		// A user created before with the same key is not created
+		// again, answer as the first time.
+		key := ctx.GetHeader("Idempotency-Key")
+		if key != "" {
+			created.Lock()
+			resp, ok := created.byKey[key]
+			created.Unlock()
+			if ok {
+				ctx.Header("Location", "/api/v1/user/"+resp["id"].(string))
+				ctx.JSON(http.StatusCreated, resp)
+				return
+			}
+		}
+
+		// Echo the user back, leaving out the fields not set.
+		resp := gin.H{
 			"id":   "ABC-111",
-			"name": "Jack",
+			"name": req.Name,
+		}
+		if req.Email != "" {
+			resp["email"] = req.Email
+		}
+		if req.Role != "" {
+			resp["role"] = req.Role
+		}
+		if key != "" {
+			created.Lock()
+			created.byKey[key] = resp
+			created.Unlock()
+		}
+		ctx.Header("Location", "/api/v1/user/"+resp["id"].(string))
+		ctx.JSON(http.StatusCreated, resp)
+	})
+	r.POST("/api/v1/users", func(ctx *gin.Context) {
+		var req []struct {
+			Name string `json:"name"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		// Users without a name are not created, the others are.
+		status := http.StatusCreated
+		results := make([]gin.H, len(req))
+		for i, user := range req {
+			if user.Name == "" {
+				status = http.StatusMultiStatus
+				results[i] = gin.H{
+					"name":   user.Name,
+					"status": http.StatusBadRequest,
+					"msg":    "name is required",
+				}
+				continue
+			}
+			results[i] = gin.H{
+				"id":     fmt.Sprintf("ABC-%d", 111+i),
+				"name":   user.Name,
+				"status": http.StatusCreated,
+			}
+		}
+		ctx.JSON(status, results)
+	})
+	r.GET("/api/v1/users/full", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, []gin.H{
+			{"id": "ABC-111", "name": "Jack"},
+			{"id": "ABC-222", "name": "Marry"},
+			{"id": "ABC-333", "name": "Sandy"},
 		})
 	})
-	r.RunUnix("mysock.sock")
+	r.GET("/api/v1/users/by-id", func(ctx *gin.Context) {
+		// Only ABC-111 exists, the other ids are left out.
+		users := []gin.H{}
+		for _, id := range ctx.QueryArray("id") {
+			if id == "ABC-111" {
+				users = append(users, gin.H{
+					"id":   "ABC-111",
+					"name": "Jack",
+				})
+			}
+		}
+		ctx.JSON(http.StatusOK, users)
+	})
+	// ABC-111, the user that can be fetched, along with its version,
+	// bumped whenever it is renamed and sent as its ETag.
+	var stored struct {
+		sync.Mutex
+		name    string
+		version int
+	}
+	stored.name = "Jack"
+	stored.version = 1
+	etag := func(version int) string {
+		return fmt.Sprintf(`"v%d"`, version)
+	}
+	r.GET("/api/v1/user/:id", func(ctx *gin.Context) {
+		if ctx.Param("id") != "ABC-111" {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"msg": "user not found",
+			})
+			return
+		}
+		stored.Lock()
+		name, version := stored.name, stored.version
+		stored.Unlock()
+		ctx.Header("ETag", etag(version))
+		ctx.JSON(http.StatusOK, gin.H{
+			"id":   "ABC-111",
+			"name": name,
+		})
+	})
+	r.PUT("/api/v1/user/:id", func(ctx *gin.Context) {
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		// The other users are not stored, just echo them back.
+		if ctx.Param("id") != "ABC-111" {
+			ctx.JSON(http.StatusOK, gin.H{
+				"id":   ctx.Param("id"),
+				"name": req.Name,
+			})
+			return
+		}
+
+		// Refuse to rename the user if it changed since the
+		// version the client has.
+		stored.Lock()
+		defer stored.Unlock()
+		if match := ctx.GetHeader("If-Match"); match != "" && match != etag(stored.version) {
+			ctx.JSON(http.StatusPreconditionFailed, gin.H{
+				"msg": "user was modified",
+			})
+			return
+		}
+		stored.name = req.Name
+		stored.version++
+		ctx.Header("ETag", etag(stored.version))
+		ctx.JSON(http.StatusOK, gin.H{
+			"id":   "ABC-111",
+			"name": req.Name,
+		})
+	})
+	r.PATCH("/api/v1/user/:id", func(ctx *gin.Context) {
+		var fields map[string]interface{}
+		if err := ctx.ShouldBindJSON(&fields); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		// Merge the fields into the stored user, the id cannot
+		// change.
+		user := gin.H{
+			"name": "Jack",
+		}
+		for key, value := range fields {
+			user[key] = value
+		}
+		user["id"] = ctx.Param("id")
+		ctx.JSON(http.StatusOK, user)
+	})
+	r.DELETE("/api/v1/user/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusNoContent)
+	})
+	return r
 }
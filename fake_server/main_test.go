@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	// Stop the server on SIGTERM, like main does. Being notified,
+	// the test process itself is not killed by the signal.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	sock := filepath.Join(t.TempDir(), "test.sock")
+
+	// Serve in the background, run returns once the server is
+	// shut down.
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, sock, http.NotFoundHandler())
+	}()
+
+	// Wait until the server accepts connections.
+	assert.Eventually(t, func() bool {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	// Signal ourselves as Ctrl-C or kill would.
+	p, err := os.FindProcess(os.Getpid())
+	assert.NoError(t, err)
+	assert.NoError(t, p.Signal(syscall.SIGTERM))
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+
+	// The socket file is gone, the next start will not fail.
+	assert.NoFileExists(t, sock)
+}
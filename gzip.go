@@ -0,0 +1,68 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decompressor is an http.RoundTripper asking the server for gzip
+// compressed responses and decompressing them. The transport would
+// do it itself, but only when it sets Accept-Encoding on its own, so
+// doing it here keeps working whatever headers a request carries.
+// Sitting below the cassette, it also keeps recorded bodies readable.
+type decompressor struct {
+	next http.RoundTripper
+}
+
+func (d decompressor) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given.
+	if req.Header.Get("Accept-Encoding") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	resp, err := d.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// A response the transport already decompressed no longer has
+	// a Content-Encoding, it is not decompressed twice.
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp, nil
+	}
+	resp.Body = &gzipBody{body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// gzipBody decompresses body as it is read. The gzip header is only
+// read on the first Read, so an empty body, e.g. of a HEAD request,
+// is not an error.
+type gzipBody struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	if b.zr == nil && b.err == nil {
+		b.zr, b.err = gzip.NewReader(b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.zr.Read(p)
+}
+
+func (b *gzipBody) Close() error {
+	if b.zr != nil {
+		b.zr.Close()
+	}
+	return b.body.Close()
+}
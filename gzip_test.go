@@ -0,0 +1,57 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGzipResponses(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handlers compress the body when the client accepts it.
+	gzipWrite := func(w http.ResponseWriter, r *http.Request, status int, body string) {
+		assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(status)
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(body))
+		zw.Close()
+	}
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		gzipWrite(w, r, http.StatusOK, `["Jack", "Marry", "Sandy"]`)
+	})
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		gzipWrite(w, r, http.StatusBadRequest, `{"msg": "bad name"}`)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, a compressed body is decoded", func(t *testing.T) {
+		// Calling a function to be tested.
+		users, err := GetUsers(sock)
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack", "Marry", "Sandy"}, users)
+	})
+
+	t.Run("unhappy path, a compressed error body is decoded", func(t *testing.T) {
+		// Calling a function to be tested.
+		_, err := CreateUser(sock, "Jack")
+
+		// Test the results of the function as we expect.
+		assert.EqualError(t, err, "api error (400): bad name")
+	})
+}
@@ -0,0 +1,16 @@
+package main
+
+// idempotencyKeyHeader carries the key the server dedupes creations
+// on: a request with a key it has already seen is answered like the
+// first one, instead of creating another user.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// WithIdempotencyKeys makes CreateUser send a random Idempotency-Key
+// when the caller did not give one in CreateUserParams. The key is
+// kept across the retries of a call, so WithRetry can then retry a
+// create on 502, 503 and 504 without duplicating the user.
+func WithIdempotencyKeys() Option {
+	return func(c *Client) {
+		c.idempotencyKeys = true
+	}
+}
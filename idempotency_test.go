@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler creates a user per new key, but the first answer
+	// for a key gets lost as if a proxy failed, so the client has to
+	// retry. It answers a known key with the user created first.
+	var (
+		mu      sync.Mutex
+		keys    []string
+		created = map[string]string{}
+	)
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		var req CreateUserRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		mu.Lock()
+		defer mu.Unlock()
+		key := r.Header.Get("Idempotency-Key")
+		keys = append(keys, key)
+		id, ok := created[key]
+		if !ok {
+			id = "id_" + req.Name
+			if key != "" {
+				created[key] = id
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"msg": "bad proxy"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(CreateUserResponse{ID: id, Name: req.Name})
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, the given key is kept across retries", func(t *testing.T) {
+		keys = nil
+		client := NewClient(sock, WithRetry(2, time.Millisecond))
+
+		user, err := client.CreateUserWithParams(CreateUserParams{Name: "Jack", IdempotencyKey: "key-jack"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "id_Jack", Name: "Jack"}, user)
+		assert.Equal(t, []string{"key-jack", "key-jack"}, keys)
+
+		// Creating it again with the same key gives the same user.
+		user, err = client.CreateUserWithParams(CreateUserParams{Name: "Jack", IdempotencyKey: "key-jack"})
+		assert.NoError(t, err)
+		assert.Equal(t, "id_Jack", user.ID)
+	})
+
+	t.Run("happy path, a generated key is kept across retries", func(t *testing.T) {
+		keys = nil
+		client := NewClient(sock, WithRetry(2, time.Millisecond), WithIdempotencyKeys())
+
+		user, err := client.CreateUser("Marry")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "id_Marry", user.ID)
+		if assert.Len(t, keys, 2) {
+			assert.Len(t, keys[0], 32)
+			assert.Equal(t, keys[0], keys[1])
+		}
+
+		// The next call gets a key of its own, so it creates
+		// another user.
+		_, err = client.CreateUser("Marry")
+		assert.NoError(t, err)
+		if assert.Len(t, keys, 4) {
+			assert.NotEqual(t, keys[0], keys[2])
+			assert.Equal(t, keys[2], keys[3])
+		}
+	})
+
+	t.Run("unhappy path, without a key a create is not retried", func(t *testing.T) {
+		keys = nil
+		client := NewClient(sock, WithRetry(2, time.Millisecond))
+
+		_, err := client.CreateUser("Sandy")
+
+		var apiErr *APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+		}
+		assert.Equal(t, []string{""}, keys)
+	})
+}
@@ -0,0 +1,118 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFakeServer runs the calls against the real gin server of
+// fake_server, rather than a mux mocking it, to catch them drifting
+// apart. Both are main packages, so the server is built and run as a
+// separate process.
+//
+//	go test -tags integration -run TestFakeServer .
+func TestFakeServer(t *testing.T) {
+	dir := t.TempDir()
+	sock := filepath.Join(dir, "fake_server.sock")
+
+	// Build the server.
+	bin := filepath.Join(dir, "fake_server")
+	build := exec.Command("go", "build", "-o", bin, "./fake_server")
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		t.Fatalf("build fake_server: %v", err)
+	}
+
+	// Start it on a socket of its own.
+	server := exec.Command(bin, "-sock", sock)
+	server.Stderr = os.Stderr
+	if err := server.Start(); err != nil {
+		t.Fatalf("start fake_server: %v", err)
+	}
+	defer server.Process.Kill()
+
+	// Wait until it accepts connections.
+	assert.Eventually(t, func() bool {
+		return Ping(context.Background(), sock) == nil
+	}, 10*time.Second, 50*time.Millisecond)
+
+	t.Run("GetUsers", func(t *testing.T) {
+		users, err := GetUsers(sock)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack", "Marry", "Sandy"}, users)
+	})
+
+	t.Run("GetUsersWithPrefix", func(t *testing.T) {
+		users, err := GetUsersWithPrefix(sock, "Ma")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Marry"}, users)
+
+		users, err = GetUsersWithPrefix(sock, "Tom")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{}, users)
+	})
+
+	t.Run("CreateUser", func(t *testing.T) {
+		user, err := CreateUser(sock, "Jack")
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Name: "Jack", Location: "/api/v1/user/ABC-111"}, user)
+	})
+
+	t.Run("CreateUserWithParams, invalid fields", func(t *testing.T) {
+		_, err := CreateUserWithParams(sock, CreateUserParams{Name: strings.Repeat("x", 33), Email: "jack"})
+		var validationErr *ValidationError
+		if assert.ErrorAs(t, err, &validationErr) {
+			assert.Equal(t, "invalid user", validationErr.Msg)
+			assert.Equal(t, map[string]string{"name": "too long", "email": "invalid"}, validationErr.Fields)
+		}
+	})
+
+	t.Run("GetUserList", func(t *testing.T) {
+		users, err := GetUserList(sock)
+		assert.NoError(t, err)
+		assert.Equal(t, []CreateUserResponse{
+			{ID: "ABC-111", Name: "Jack"},
+			{ID: "ABC-222", Name: "Marry"},
+			{ID: "ABC-333", Name: "Sandy"},
+		}, users)
+	})
+
+	t.Run("GetUsersByIDs", func(t *testing.T) {
+		users, err := GetUsersByIDs(sock, []string{"ABC-111", "ABC-999"})
+		assert.NoError(t, err)
+		assert.Equal(t, []CreateUserResponse{{ID: "ABC-111", Name: "Jack"}}, users)
+	})
+
+	t.Run("UpdateUserIfMatch", func(t *testing.T) {
+		user, err := GetUser(sock, "ABC-111")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NotEmpty(t, user.ETag)
+
+		// The first update with the ETag wins, the second one is
+		// stale.
+		updated, err := UpdateUserIfMatch(sock, "ABC-111", "Jill", user.ETag)
+		assert.NoError(t, err)
+		assert.Equal(t, "Jill", updated.Name)
+		assert.NotEqual(t, user.ETag, updated.ETag)
+
+		_, err = UpdateUserIfMatch(sock, "ABC-111", "Sandy", user.ETag)
+		assert.ErrorIs(t, err, ErrPreconditionFailed)
+	})
+
+	// Stop the server as Ctrl-C would, it cleans its socket up.
+	assert.NoError(t, server.Process.Signal(syscall.SIGINT))
+	assert.NoError(t, server.Wait())
+	assert.NoFileExists(t, sock)
+}
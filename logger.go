@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Logger records the requests sent by a Client, set WithLogger.
+type Logger interface {
+	// Log is called once per request sent, with the status of the
+	// response, or 0 and the error if there was none, and how long
+	// the round trip took.
+	Log(method, path string, status int, dur time.Duration, err error)
+}
+
+// WithLogger makes the Client report every request it sends to l.
+func WithLogger(l Logger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// StdLogger is a Logger writing a line per request to Logger, or to
+// the standard logger of the log package if Logger is nil.
+type StdLogger struct {
+	Logger *log.Logger
+}
+
+func (l StdLogger) Log(method, path string, status int, dur time.Duration, err error) {
+	logf := log.Printf
+	if l.Logger != nil {
+		logf = l.Logger.Printf
+	}
+
+	if err != nil {
+		logf("%s %s: %v (%v)", method, path, err, dur)
+		return
+	}
+	logf("%s %s: %d (%v)", method, path, status, dur)
+}
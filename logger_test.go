@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// logEntry is a call to Logger.Log.
+type logEntry struct {
+	method string
+	path   string
+	status int
+	err    error
+}
+
+// capturingLogger is a Logger remembering every call.
+type capturingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *capturingLogger) Log(method, path string, status int, dur time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{method: method, path: path, status: status, err: err})
+}
+
+func TestWithLogger(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"msg": "bad name"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("every request is logged", func(t *testing.T) {
+		logger := &capturingLogger{}
+		client := NewClient(sock, WithLogger(logger))
+
+		client.GetUsers()
+		client.CreateUser("Jack")
+
+		assert.Equal(t, []logEntry{
+			{method: http.MethodGet, path: "/api/v1/users", status: http.StatusOK},
+			{method: http.MethodPost, path: "/api/v1/user", status: http.StatusBadRequest},
+		}, logger.entries)
+	})
+
+	t.Run("a failed dial is logged with its error", func(t *testing.T) {
+		logger := &capturingLogger{}
+		client := NewClient("missing.sock", WithLogger(logger))
+
+		client.GetUsers()
+
+		if assert.Len(t, logger.entries, 1) {
+			assert.Equal(t, 0, logger.entries[0].status)
+			var connErr *ConnectError
+			assert.True(t, errors.As(logger.entries[0].err, &connErr))
+		}
+	})
+
+	t.Run("StdLogger writes a line per request", func(t *testing.T) {
+		var buf bytes.Buffer
+		client := NewClient(sock, WithLogger(StdLogger{Logger: log.New(&buf, "", 0)}))
+
+		client.GetUsers()
+
+		assert.True(t, strings.HasPrefix(buf.String(), "GET /api/v1/users: 200 ("), buf.String())
+	})
+}
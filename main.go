@@ -1,27 +1,28 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"net"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 func main() {
-	sock := "mysock.sock"
-	GetUsers(sock)
-	CreateUser(sock, "Jack")
-}
-
-type errorResponse struct {
-	Msg string `json:"msg"`
+	client := NewClient(DefaultSocket())
+	client.GetUsers()
+	client.CreateUser("Jack")
 }
 
 // GetUsers send http GET request to /api/v1/users endpoint
-// of mysock.sock to get a list of users.
+// of the socket to get a list of users.
 //
 // Expect 200 OK and the following response format.
 //
@@ -33,73 +34,325 @@ type errorResponse struct {
 //
 // ]
 //
+// Null entries of the list are dropped, LastStats tells how many in
+// SkippedUsers.
+//
+// Only the names are sent, use GetUserList to get the ids as well.
+//
 // If it is not 200 OK, it will return 4xx or 5xx with following message
 // format.
 //
 //	{
 //		"msg": "something wrong!"
 //	}
+func (c *Client) GetUsers() ([]string, error) {
+	return c.GetUsersContext(context.Background())
+}
+
+// GetUsersContext is like GetUsers, but the request is bound to ctx,
+// so canceling ctx aborts both the dial and the round trip.
+func (c *Client) GetUsersContext(ctx context.Context) ([]string, error) {
+	var data userList
+	err := c.send(ctx, getUsersEndpoint, getUsersEndpoint.url(), nil, &data)
+	if err != nil {
+		return nil, err
+	}
+	c.recordSkipped(data.skipped)
+	return data.names, nil
+}
+
+// GetUsersRaw is like GetUsers, but also returns the raw response
+// body, e.g. for logging what the server sent. The body is returned
+// even if decoding it fails.
+func (c *Client) GetUsersRaw() ([]string, []byte, error) {
+	var data userList
+	raw, err := c.sendRaw(context.Background(), getUsersEndpoint, getUsersEndpoint.url(), nil, &data)
+	if err != nil {
+		return nil, raw, err
+	}
+	c.recordSkipped(data.skipped)
+	return data.names, raw, nil
+}
+
+// GetUsers is like Client.GetUsers, using a one-shot client of sock.
 func GetUsers(sock string) ([]string, error) {
-	// Create an UDS-based http client.
-	client := http.Client{
-		Transport: &http.Transport{
-			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				// The default transport protocol for
-				// HTTP clients is TCP, which we can
-				// modify to UDS by creating a new
-				// Unix Domain Socket connection.
-				return net.Dial("unix", sock)
-			},
-		},
+	return oneShotClient(sock).GetUsers()
+}
+
+// GetUserList send http GET request to /api/v1/users/full endpoint
+// of the socket to get the list of users with all their fields.
+//
+// Expect 200 OK and the following response format:
+//
+//	[
+//		{
+//			"id": "ABC-111",
+//			"name": "Jack"
+//		}
+//	]
+//
+// Use it when the ids are needed, e.g. to call GetUser or DeleteUser
+// next. GetUsers is enough, and lighter, when only the names are.
+func (c *Client) GetUserList() ([]CreateUserResponse, error) {
+	return c.GetUserListContext(context.Background())
+}
+
+// GetUserListContext is like GetUserList, but the request is bound to
+// ctx.
+func (c *Client) GetUserListContext(ctx context.Context) ([]CreateUserResponse, error) {
+	var data []CreateUserResponse
+	err := c.send(ctx, getUserListEndpoint, getUserListEndpoint.url(), nil, c.userTarget(&data))
+	if err != nil {
+		return nil, err
 	}
+	return data, nil
+}
 
-	// Send the http request to the server.
-	// For UDS-based HTTP, the domain in the URL
-	// is not important and is ignored here with
-	// an underscore (_).
-	resp, err := client.Get("http://_/api/v1/users")
+// GetUserList is like Client.GetUserList, using a one-shot client of
+// sock.
+func GetUserList(sock string) ([]CreateUserResponse, error) {
+	return oneShotClient(sock).GetUserList()
+}
+
+// GetUsersRaw is like Client.GetUsersRaw, using a one-shot client of
+// sock.
+func GetUsersRaw(sock string) ([]string, []byte, error) {
+	return oneShotClient(sock).GetUsersRaw()
+}
+
+// GetUsersContext is like Client.GetUsersContext, using a one-shot
+// client of sock.
+func GetUsersContext(ctx context.Context, sock string) ([]string, error) {
+	return oneShotClient(sock).GetUsersContext(ctx)
+}
+
+// userList is a list of user names. The null entries a server may
+// send are dropped rather than turned into empty names, and counted.
+type userList struct {
+	names   []string
+	skipped int
+}
+
+func (l *userList) UnmarshalJSON(b []byte) error {
+	var names []*string
+	if err := json.Unmarshal(b, &names); err != nil {
+		return err
+	}
+	if names == nil {
+		return nil
+	}
+
+	l.names = make([]string, 0, len(names))
+	for _, name := range names {
+		if name == nil {
+			l.skipped++
+			continue
+		}
+		l.names = append(l.names, *name)
+	}
+	return nil
+}
+
+// usersPage is a page of users along with the number of users in
+// total. Decoding into an integer field parses the number as such, so
+// a total above 2^53 is not rounded as it would be through a float64.
+// It is an int64, to hold such a total on 32-bit platforms too.
+type usersPage struct {
+	Users userList `json:"users"`
+	Total int64    `json:"total"`
+}
+
+// GetUsersPage send http GET request to /api/v1/users endpoint with
+// page and page_size query parameters to get one page of the users,
+// pages being numbered from 1. The total number of users is returned
+// along with the page.
+//
+// Expect 200 OK and the following response format:
+//
+//	{
+//		"users": ["Jack", "Marry"],
+//		"total": 42
+//	}
+//
+// If it is not 200 OK, it will return 4xx or 5xx with following message
+// format:
+//
+//	{
+//		"msg": "something wrong!"
+//	}
+func (c *Client) GetUsersPage(page, pageSize int) ([]string, int64, error) {
+	return c.GetUsersPageContext(context.Background(), page, pageSize)
+}
+
+// GetUsersPageContext is like GetUsersPage, but the request is bound
+// to ctx.
+func (c *Client) GetUsersPageContext(ctx context.Context, page, pageSize int) ([]string, int64, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, fmt.Errorf("invalid page %d of size %d", page, pageSize)
+	}
+
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("page_size", strconv.Itoa(pageSize))
+
+	var data usersPage
+	err := c.send(ctx, getUsersEndpoint, getUsersEndpoint.url()+"?"+query.Encode(), nil, &data)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.recordSkipped(data.Users.skipped)
+	return data.Users.names, data.Total, nil
+}
+
+// GetUsersPage is like Client.GetUsersPage, using a one-shot client of
+// sock.
+func GetUsersPage(sock string, page, pageSize int) ([]string, int64, error) {
+	return oneShotClient(sock).GetUsersPage(page, pageSize)
+}
+
+// SearchUsers send http GET request to /api/v1/users endpoint with a
+// q query parameter to get the names of the users matching query.
+// The response format is the same as of GetUsers.
+func (c *Client) SearchUsers(query string) ([]string, error) {
+	return c.SearchUsersContext(context.Background(), query)
+}
+
+// SearchUsersContext is like SearchUsers, but the request is bound to
+// ctx.
+func (c *Client) SearchUsersContext(ctx context.Context, query string) ([]string, error) {
+	// The query may hold spaces or '&', it must be encoded.
+	q := url.Values{}
+	q.Set("q", query)
+
+	var data userList
+	err := c.send(ctx, getUsersEndpoint, getUsersEndpoint.url()+"?"+q.Encode(), nil, &data)
 	if err != nil {
 		return nil, err
 	}
+	c.recordSkipped(data.skipped)
+	return data.names, nil
+}
 
-	// Reading and parsing the response body.
-	body, err := io.ReadAll(resp.Body)
+// SearchUsers is like Client.SearchUsers, using a one-shot client of
+// sock.
+func SearchUsers(sock, query string) ([]string, error) {
+	return oneShotClient(sock).SearchUsers(query)
+}
+
+// GetUsersWithPrefix is like GetUsers, but asks the server to only
+// return the users whose name starts with prefix, with the prefix
+// query parameter. An empty prefix returns every user. If no user
+// matches, the slice is empty rather than nil.
+func (c *Client) GetUsersWithPrefix(prefix string) ([]string, error) {
+	return c.GetUsersWithPrefixContext(context.Background(), prefix)
+}
+
+// GetUsersWithPrefixContext is like GetUsersWithPrefix, but the
+// request is bound to ctx.
+func (c *Client) GetUsersWithPrefixContext(ctx context.Context, prefix string) ([]string, error) {
+	target := getUsersEndpoint.url()
+	if prefix != "" {
+		// The prefix may hold spaces or '&', it must be encoded.
+		q := url.Values{}
+		q.Set("prefix", prefix)
+		target += "?" + q.Encode()
+	}
+
+	var data userList
+	err := c.send(ctx, getUsersEndpoint, target, nil, &data)
 	if err != nil {
 		return nil, err
 	}
+	c.recordSkipped(data.skipped)
 
-	if resp.StatusCode == http.StatusOK {
-		// If the request is successful,
-		// return the user information.
-		var data []string
-		err = json.Unmarshal(body, &data)
-		if err != nil {
-			return nil, err
-		}
-		return data, err
-	} else {
-		// If it fails, return the "msg" in the
-		// response body.
-		var data errorResponse
-		err = json.Unmarshal(body, &data)
-		if err != nil {
-			return nil, err
-		}
-		return nil, errors.New(data.Msg)
+	// A server answering null still means no user matched.
+	if data.names == nil {
+		return []string{}, nil
 	}
+	return data.names, nil
+}
+
+// GetUsersWithPrefix is like Client.GetUsersWithPrefix, using a
+// one-shot client of sock.
+func GetUsersWithPrefix(sock, prefix string) ([]string, error) {
+	return oneShotClient(sock).GetUsersWithPrefix(prefix)
+}
+
+// GetUsersSorted is like GetUsers, but asks the server to sort the
+// users by the field sortBy, e.g. "name", in order "asc" or "desc",
+// with the sort and order query parameters. Any other order is
+// rejected before anything is sent.
+func (c *Client) GetUsersSorted(sortBy, order string) ([]string, error) {
+	return c.GetUsersSortedContext(context.Background(), sortBy, order)
 }
 
+// GetUsersSortedContext is like GetUsersSorted, but the request is
+// bound to ctx.
+func (c *Client) GetUsersSortedContext(ctx context.Context, sortBy, order string) ([]string, error) {
+	if order != "asc" && order != "desc" {
+		return nil, fmt.Errorf("invalid sort order %q, want asc or desc", order)
+	}
+
+	query := url.Values{}
+	query.Set("sort", sortBy)
+	query.Set("order", order)
+
+	var data userList
+	err := c.send(ctx, getUsersEndpoint, getUsersEndpoint.url()+"?"+query.Encode(), nil, &data)
+	if err != nil {
+		return nil, err
+	}
+	c.recordSkipped(data.skipped)
+	return data.names, nil
+}
+
+// GetUsersSorted is like Client.GetUsersSorted, using a one-shot
+// client of sock.
+func GetUsersSorted(sock, sortBy, order string) ([]string, error) {
+	return oneShotClient(sock).GetUsersSorted(sortBy, order)
+}
+
+// ErrEmptyUserName is returned, before anything is sent, when the
+// name of a user to create is empty or only whitespace.
+var ErrEmptyUserName = errors.New("empty user name")
+
 type CreateUserRequest struct {
 	Name string `json:"name"`
 }
 
+// CreateUserParams describes a user to create. Email and Role are
+// only sent when set.
+type CreateUserParams struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	Role  string `json:"role,omitempty"`
+
+	// IdempotencyKey, if set, is sent as the Idempotency-Key
+	// header, so creating the user again with the same key does not
+	// duplicate it. See WithIdempotencyKeys.
+	IdempotencyKey string `json:"-"`
+}
+
 type CreateUserResponse struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	Role  string `json:"role,omitempty"`
+
+	// Location is where the created user can be fetched, e.g.
+	// "/api/v1/user/ABC-111", from the Location header the server
+	// answered CreateUser with. It is empty if there was none.
+	Location string `json:"-"`
+
+	// ETag is the version of the user the server answered GetUser
+	// or UpdateUser with, from the ETag header. Passing it to
+	// UpdateUserIfMatch makes sure the user was not modified since.
+	// It is empty if there was none.
+	ETag string `json:"-"`
 }
 
 // CreateUser send http POST request to /api/v1/user endpoint
-// of mysock.sock to create a user.
+// of the socket to create a user.
 //
 // Payload format:
 //
@@ -114,78 +367,988 @@ type CreateUserResponse struct {
 //		"name": "Jack"
 //	}
 //
+// A 201 Created without a body is a success too, the id is then taken
+// from the last segment of the Location header, if any.
+//
 // If it is not 201 Created, it will return 4xx or 5xx with following message
 // format:
 //
 //	{
 //		"msg": "something wrong!"
 //	}
+func (c *Client) CreateUser(userName string) (*CreateUserResponse, error) {
+	return c.CreateUserContext(context.Background(), userName)
+}
+
+// CreateUserContext is like CreateUser, but the request is bound to
+// ctx, so canceling ctx aborts both the dial and the round trip.
+func (c *Client) CreateUserContext(ctx context.Context, userName string) (*CreateUserResponse, error) {
+	return c.CreateUserWithParamsContext(ctx, CreateUserParams{Name: userName})
+}
+
+// CreateUserWithParams is like CreateUser, but the user may carry
+// more than a name.
+//
+// Payload format:
+//
+//	{
+//		"name": "Jack",
+//		"email": "jack@example.com",
+//		"role": "admin"
+//	}
+//
+// Expect 201 Created and the user echoed back along with its id.
+func (c *Client) CreateUserWithParams(params CreateUserParams) (*CreateUserResponse, error) {
+	return c.CreateUserWithParamsContext(context.Background(), params)
+}
+
+// CreateUserWithParamsContext is like CreateUserWithParams, but the
+// request is bound to ctx.
+func (c *Client) CreateUserWithParamsContext(ctx context.Context, params CreateUserParams) (*CreateUserResponse, error) {
+	// The server would reject it anyway, spare the round trip.
+	if strings.TrimSpace(params.Name) == "" {
+		return nil, ErrEmptyUserName
+	}
+
+	// The key is set once for all the attempts of the request.
+	var opts []requestOption
+	key := params.IdempotencyKey
+	if key == "" && c.idempotencyKeys {
+		key = newRequestID()
+	}
+	if key != "" {
+		opts = append(opts, withRequestHeader(idempotencyKeyHeader, key))
+	}
+
+	var payload interface{} = params
+	if c.contentType == ContentTypeForm {
+		form := url.Values{}
+		form.Set("name", params.Name)
+		if params.Email != "" {
+			form.Set("email", params.Email)
+		}
+		if params.Role != "" {
+			form.Set("role", params.Role)
+		}
+		payload = form
+	}
+
+	var data CreateUserResponse
+	resp, err := c.sendResponse(ctx, createUserEndpoint, createUserEndpoint.url(), payload, c.userTarget(&data), opts...)
+	if err != nil {
+		return nil, err
+	}
+	data.Location = resp.Header.Get("Location")
+
+	// Without a body, the id is the last segment of the Location,
+	// e.g. "/api/v1/user/ABC-111".
+	if data.ID == "" && data.Location != "" {
+		if u, err := url.Parse(data.Location); err == nil {
+			data.ID = path.Base(u.Path)
+		}
+	}
+	return &data, nil
+}
+
+// CreateUser is like Client.CreateUser, using a one-shot client of sock.
 func CreateUser(sock, userName string) (*CreateUserResponse, error) {
-	// Create an UDS-based http client.
-	client := http.Client{
-		Transport: &http.Transport{
-			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				// The default transport protocol for
-				// HTTP clients is TCP, which we can
-				// modify to UDS by creating a new
-				// Unix Domain Socket connection.
-				return net.Dial("unix", sock)
-			},
-		},
+	return oneShotClient(sock).CreateUser(userName)
+}
+
+// CreateUserContext is like Client.CreateUserContext, using a one-shot
+// client of sock.
+func CreateUserContext(ctx context.Context, sock, userName string) (*CreateUserResponse, error) {
+	return oneShotClient(sock).CreateUserContext(ctx, userName)
+}
+
+// CreateUserWithParams is like Client.CreateUserWithParams, using a
+// one-shot client of sock.
+func CreateUserWithParams(sock string, params CreateUserParams) (*CreateUserResponse, error) {
+	return oneShotClient(sock).CreateUserWithParams(params)
+}
+
+// createUserResult is the outcome of creating one user of a batch.
+type createUserResult struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Status and Msg are only set in a 207 Multi-Status response.
+	Status int    `json:"status"`
+	Msg    string `json:"msg"`
+}
+
+// CreateUsers send http POST request to /api/v1/users endpoint
+// of the socket to create several users in one round trip.
+//
+// Payload format:
+//
+//	[
+//		{"name": "Jack"},
+//		{"name": "Marry"}
+//	]
+//
+// Expect 201 Created and the following response format:
+//
+//	[
+//		{"id": "ABC-111", "name": "Jack"},
+//		{"id": "ABC-112", "name": "Marry"}
+//	]
+//
+// If only some users were created, expect 207 Multi-Status with the
+// status of each user:
+//
+//	[
+//		{"id": "ABC-111", "name": "Jack", "status": 201},
+//		{"name": "Marry", "status": 409, "msg": "user already exists"}
+//	]
+//
+// The created users are then returned along with a *BatchError
+// listing the others. Any other status is returned as an *APIError.
+func (c *Client) CreateUsers(names []string) ([]CreateUserResponse, error) {
+	return c.CreateUsersContext(context.Background(), names)
+}
+
+// CreateUsersContext is like CreateUsers, but the request is bound to
+// ctx.
+func (c *Client) CreateUsersContext(ctx context.Context, names []string) ([]CreateUserResponse, error) {
+	if len(names) == 0 {
+		return nil, nil
 	}
 
 	// Create a payload that should be POSTed to the server.
+	payload := make([]CreateUserRequest, len(names))
+	for i, name := range names {
+		payload[i] = CreateUserRequest{Name: name}
+	}
+
+	var results []createUserResult
+	err := c.send(ctx, createUsersEndpoint, createUsersEndpoint.url(), payload, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	// Split the created users from the failed ones. The status is
+	// absent when all of them were created.
+	created := make([]CreateUserResponse, 0, len(results))
+	var batchErr BatchError
+	for _, r := range results {
+		if r.Status != 0 && (r.Status < 200 || r.Status >= 300) {
+			batchErr.Failures = append(batchErr.Failures, BatchFailure{
+				Name: r.Name,
+				Err:  newAPIError(r.Status, []byte(r.Msg)),
+			})
+			continue
+		}
+		created = append(created, CreateUserResponse{ID: r.ID, Name: r.Name})
+	}
+	if len(batchErr.Failures) > 0 {
+		return created, &batchErr
+	}
+	return created, nil
+}
+
+// CreateUsers is like Client.CreateUsers, using a one-shot client of
+// sock.
+func CreateUsers(sock string, names []string) ([]CreateUserResponse, error) {
+	return oneShotClient(sock).CreateUsers(names)
+}
+
+// CreateUsersChunked is like CreateUsers, but sends the names in
+// batches of at most chunkSize, one after the other, so a large
+// import does not end up in a single oversized request. It stops at
+// the first batch that fails and returns the users created so far,
+// in order, along with the error.
+func (c *Client) CreateUsersChunked(names []string, chunkSize int) ([]CreateUserResponse, error) {
+	return c.CreateUsersChunkedContext(context.Background(), names, chunkSize)
+}
+
+// CreateUsersChunkedContext is like CreateUsersChunked, but the
+// requests are bound to ctx.
+func (c *Client) CreateUsersChunkedContext(ctx context.Context, names []string, chunkSize int) ([]CreateUserResponse, error) {
+	if chunkSize < 1 {
+		return nil, fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+
+	created := make([]CreateUserResponse, 0, len(names))
+	for start := 0; start < len(names); start += chunkSize {
+		end := start + chunkSize
+		if end > len(names) {
+			end = len(names)
+		}
+
+		// A partly failed batch still created some users, keep
+		// them before giving up.
+		users, err := c.CreateUsersContext(ctx, names[start:end])
+		created = append(created, users...)
+		if err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+// CreateUsersChunked is like Client.CreateUsersChunked, using a
+// one-shot client of sock.
+func CreateUsersChunked(sock string, names []string, chunkSize int) ([]CreateUserResponse, error) {
+	return oneShotClient(sock).CreateUsersChunked(names, chunkSize)
+}
+
+// ErrUserNotFound is wrapped by the *APIError that GetUser, UpdateUser,
+// PatchUser and DeleteUser return when the server answers 404 Not
+// Found.
+var ErrUserNotFound = errors.New("user not found")
+
+// GetUser send http GET request to /api/v1/user/{id} endpoint
+// of the socket to get the user with the given id.
+//
+// Expect 200 OK and the following response format:
+//
+//	{
+//		"id": "ABC-111",
+//		"name": "Jack"
+//	}
+//
+// If it is not 200 OK, e.g. 404 Not Found for an unknown id, it will
+// return 4xx or 5xx with following message format:
+//
+//	{
+//		"msg": "something wrong!"
+//	}
+func (c *Client) GetUser(id string) (*CreateUserResponse, error) {
+	return c.GetUserContext(context.Background(), id)
+}
+
+// GetUserContext is like GetUser, but the request is bound to ctx.
+func (c *Client) GetUserContext(ctx context.Context, id string) (*CreateUserResponse, error) {
+	if c.autoUnwrap {
+		var raw json.RawMessage
+		resp, err := c.sendResponse(ctx, getUserEndpoint, getUserEndpoint.url(id), nil, &raw)
+		if err != nil {
+			return nil, err
+		}
+		var data CreateUserResponse
+		err = c.unmarshal(unwrapEnvelope(raw), c.userTarget(&data))
+		if err != nil {
+			return nil, &DecodeError{Body: raw, Err: err}
+		}
+		data.ETag = resp.Header.Get("ETag")
+		return &data, nil
+	}
+
+	var data CreateUserResponse
+	resp, err := c.sendResponse(ctx, getUserEndpoint, getUserEndpoint.url(id), nil, c.userTarget(&data))
+	if err != nil {
+		return nil, err
+	}
+	data.ETag = resp.Header.Get("ETag")
+	return &data, nil
+}
+
+// GetUser is like Client.GetUser, using a one-shot client of sock.
+func GetUser(sock, id string) (*CreateUserResponse, error) {
+	return oneShotClient(sock).GetUser(id)
+}
+
+// GetUsersConcurrent gets the users with the given ids, with up to
+// concurrency GetUser requests in flight at once, and returns them
+// keyed by id. An id the server does not know is left out of the map.
+// Any other error cancels the requests still to go and is returned,
+// as is the error of ctx if it is done first.
+func (c *Client) GetUsersConcurrent(ctx context.Context, ids []string, concurrency int) (map[string]*CreateUserResponse, error) {
+	if concurrency < 1 {
+		return nil, fmt.Errorf("invalid concurrency %d", concurrency)
+	}
+
+	// Canceling ctx on the first error aborts the siblings.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		users    = make(map[string]*CreateUserResponse, len(ids))
+		firstErr error
+	)
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(ids); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				// The feeder may still hand out an id as
+				// ctx gets done, skip it.
+				if ctx.Err() != nil {
+					continue
+				}
+				user, err := c.GetUserContext(ctx, id)
+				mu.Lock()
+				switch {
+				case err == nil:
+					users[id] = user
+				case errors.Is(err, ErrUserNotFound):
+				case firstErr == nil && ctx.Err() == nil:
+					// Once ctx is done, the siblings fail
+					// because of it, not worth reporting.
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// Stop handing out ids once ctx is done, by the caller or by an
+	// error.
+feed:
+	for _, id := range ids {
+		select {
+		case jobs <- id:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUsersConcurrent is like Client.GetUsersConcurrent, using a
+// one-shot client of sock.
+func GetUsersConcurrent(ctx context.Context, sock string, ids []string, concurrency int) (map[string]*CreateUserResponse, error) {
+	return oneShotClient(sock).GetUsersConcurrent(ctx, ids, concurrency)
+}
+
+// GetUsersByIDs send http GET request to /api/v1/users/by-id endpoint
+// of the socket to get the users with the given ids in one go, with
+// an id query parameter per id. The same id is only asked once.
+//
+// Expect 200 OK and the users found, in no particular order, the
+// unknown ids being left out:
+//
+//	[
+//		{
+//			"id": "ABC-111",
+//			"name": "Jack"
+//		}
+//	]
+func (c *Client) GetUsersByIDs(ids []string) ([]CreateUserResponse, error) {
+	return c.GetUsersByIDsContext(context.Background(), ids)
+}
+
+// GetUsersByIDsContext is like GetUsersByIDs, but the request is bound
+// to ctx.
+func (c *Client) GetUsersByIDsContext(ctx context.Context, ids []string) ([]CreateUserResponse, error) {
+	q := url.Values{}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			q.Add("id", id)
+		}
+	}
+
+	// No user can match, spare the round trip.
+	if len(q) == 0 {
+		return nil, nil
+	}
+
+	var data []CreateUserResponse
+	err := c.send(ctx, getUsersByIDsEndpoint, getUsersByIDsEndpoint.url()+"?"+q.Encode(), nil, c.userTarget(&data))
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// GetUsersByIDs is like Client.GetUsersByIDs, using a one-shot client
+// of sock.
+func GetUsersByIDs(sock string, ids []string) ([]CreateUserResponse, error) {
+	return oneShotClient(sock).GetUsersByIDs(ids)
+}
+
+// UpdateUser send http PUT request to /api/v1/user/{id} endpoint
+// of the socket to rename the user with the given id.
+//
+// Payload format:
+//
+//	{
+//		"name": "Jack"
+//	}
+//
+// Expect 200 OK and the updated user in the following response format:
+//
+//	{
+//		"id": "ABC-111",
+//		"name": "Jack"
+//	}
+//
+// If it is not 200 OK, it will return 4xx or 5xx with following message
+// format:
+//
+//	{
+//		"msg": "something wrong!"
+//	}
+func (c *Client) UpdateUser(id, newName string) (*CreateUserResponse, error) {
+	return c.UpdateUserContext(context.Background(), id, newName)
+}
+
+// UpdateUserContext is like UpdateUser, but the request is bound to
+// ctx.
+func (c *Client) UpdateUserContext(ctx context.Context, id, newName string) (*CreateUserResponse, error) {
+	return c.updateUser(ctx, id, newName)
+}
+
+// updateUser renames the user with the given id, with opts applied to
+// the request, e.g. an If-Match header.
+func (c *Client) updateUser(ctx context.Context, id, newName string, opts ...requestOption) (*CreateUserResponse, error) {
+	// Create a payload that should be PUT to the server.
 	payload := CreateUserRequest{
-		Name: userName,
+		Name: newName,
+	}
+
+	var data CreateUserResponse
+	resp, err := c.sendResponse(ctx, updateUserEndpoint, updateUserEndpoint.url(id), payload, c.userTarget(&data), opts...)
+	if err != nil {
+		return nil, err
+	}
+	data.ETag = resp.Header.Get("ETag")
+	return &data, nil
+}
+
+// UpdateUser is like Client.UpdateUser, using a one-shot client of sock.
+func UpdateUser(sock, id, newName string) (*CreateUserResponse, error) {
+	return oneShotClient(sock).UpdateUser(id, newName)
+}
+
+// UpdateUserIfMatch is like UpdateUser, but the user is only renamed
+// if its version is still etag, as got from GetUser, sent as the
+// If-Match header. Otherwise, someone else updated the user in the
+// meantime, and the *APIError returned wraps ErrPreconditionFailed;
+// get the user again to see what changed before retrying. An empty
+// etag sends no If-Match, the user is then renamed in any case.
+func (c *Client) UpdateUserIfMatch(id, newName, etag string) (*CreateUserResponse, error) {
+	return c.UpdateUserIfMatchContext(context.Background(), id, newName, etag)
+}
+
+// UpdateUserIfMatchContext is like UpdateUserIfMatch, but the request
+// is bound to ctx.
+func (c *Client) UpdateUserIfMatchContext(ctx context.Context, id, newName, etag string) (*CreateUserResponse, error) {
+	if etag == "" {
+		return c.updateUser(ctx, id, newName)
+	}
+	return c.updateUser(ctx, id, newName, withRequestHeader("If-Match", etag))
+}
+
+// UpdateUserIfMatch is like Client.UpdateUserIfMatch, using a one-shot
+// client of sock.
+func UpdateUserIfMatch(sock, id, newName, etag string) (*CreateUserResponse, error) {
+	return oneShotClient(sock).UpdateUserIfMatch(id, newName, etag)
+}
+
+// ErrEmptyPatch is returned, before anything is sent, by PatchUser
+// and PatchUserWithParams when there is no field to change.
+var ErrEmptyPatch = errors.New("no field to patch")
+
+// PatchUser send http PATCH request to /api/v1/user/{id} endpoint
+// of the socket to change only the given fields of the user with the
+// given id, the others are left as they are.
+//
+// Payload format, e.g. to only rename the user:
+//
+//	{
+//		"name": "Jack"
+//	}
+//
+// Expect 200 OK and the whole updated user, as for UpdateUser.
+func (c *Client) PatchUser(id string, fields map[string]interface{}) (*CreateUserResponse, error) {
+	return c.PatchUserContext(context.Background(), id, fields)
+}
+
+// PatchUserContext is like PatchUser, but the request is bound to
+// ctx.
+func (c *Client) PatchUserContext(ctx context.Context, id string, fields map[string]interface{}) (*CreateUserResponse, error) {
+	// Nothing would change, spare the round trip.
+	if len(fields) == 0 {
+		return nil, ErrEmptyPatch
 	}
 
-	// Encode the payload into json format.
-	var buf bytes.Buffer
-	err := json.NewEncoder(&buf).Encode(payload)
+	var data CreateUserResponse
+	err := c.send(ctx, patchUserEndpoint, patchUserEndpoint.url(id), fields, c.userTarget(&data))
 	if err != nil {
 		return nil, err
 	}
+	return &data, nil
+}
+
+// PatchUser is like Client.PatchUser, using a one-shot client of sock.
+func PatchUser(sock, id string, fields map[string]interface{}) (*CreateUserResponse, error) {
+	return oneShotClient(sock).PatchUser(id, fields)
+}
 
-	// Create a new http POST request with the payload
-	// and modify the Content-Type header.
+// UpdateUserParams describes the fields of a user to change with
+// PatchUserWithParams. A nil field is left out, and the user keeps
+// its value, while a field pointing to "" is sent and clears it,
+// e.g. to remove the email of a user.
+type UpdateUserParams struct {
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+	Role  *string `json:"role,omitempty"`
+}
+
+// PatchUserWithParams is like PatchUser, but the fields to change are
+// given as params.
+//
+// Payload format, e.g. to clear the email of the user:
+//
+//	{
+//		"email": ""
+//	}
+func (c *Client) PatchUserWithParams(id string, params UpdateUserParams) (*CreateUserResponse, error) {
+	return c.PatchUserWithParamsContext(context.Background(), id, params)
+}
+
+// PatchUserWithParamsContext is like PatchUserWithParams, but the
+// request is bound to ctx.
+func (c *Client) PatchUserWithParamsContext(ctx context.Context, id string, params UpdateUserParams) (*CreateUserResponse, error) {
+	// Nothing would change, spare the round trip.
+	if params == (UpdateUserParams{}) {
+		return nil, ErrEmptyPatch
+	}
+
+	var data CreateUserResponse
+	err := c.send(ctx, patchUserEndpoint, patchUserEndpoint.url(id), params, c.userTarget(&data))
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// PatchUserWithParams is like Client.PatchUserWithParams, using a
+// one-shot client of sock.
+func PatchUserWithParams(sock, id string, params UpdateUserParams) (*CreateUserResponse, error) {
+	return oneShotClient(sock).PatchUserWithParams(id, params)
+}
+
+// DeleteUser send http DELETE request to /api/v1/user/{id} endpoint
+// of the socket to delete the user with the given id.
+//
+// Expect 204 No Content. If it is not 204 No Content, it will return
+// 4xx or 5xx with following message format:
+//
+//	{
+//		"msg": "something wrong!"
+//	}
+func (c *Client) DeleteUser(id string) error {
+	return c.DeleteUserContext(context.Background(), id)
+}
+
+// DeleteUserContext is like DeleteUser, but the request is bound to
+// ctx.
+func (c *Client) DeleteUserContext(ctx context.Context, id string) error {
+	return c.send(ctx, deleteUserEndpoint, deleteUserEndpoint.url(id), nil, nil)
+}
+
+// DeleteUser is like Client.DeleteUser, using a one-shot client of sock.
+func DeleteUser(sock, id string) error {
+	return oneShotClient(sock).DeleteUser(id)
+}
+
+// DeleteUsers deletes the users with the given ids, with up to
+// WithBulkWorkers requests in flight at once. Unlike DeleteUser in a
+// loop, it does not stop at the first error: the ids that were
+// deleted are returned in the order given, the others in failed
+// along with why, e.g. an *APIError wrapping ErrUserNotFound for an
+// id that does not exist. failed is nil when every user was deleted.
+// An id given twice is deleted once.
+func (c *Client) DeleteUsers(ids []string) (deleted []string, failed map[string]error) {
+	return c.DeleteUsersContext(context.Background(), ids)
+}
+
+// DeleteUsersContext is like DeleteUsers, but the requests are bound
+// to ctx.
+func (c *Client) DeleteUsersContext(ctx context.Context, ids []string) (deleted []string, failed map[string]error) {
+	// Deleting an id twice would report the second one as not found.
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	ids = unique
+
+	// Each worker writes to its own slot, so the results need no lock.
+	errs := make([]error, len(ids))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < c.bulkWorkers && w < len(ids); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = c.DeleteUserContext(ctx, ids[i])
+			}
+		}()
+	}
+	for i := range ids {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, id := range ids {
+		if errs[i] != nil {
+			if failed == nil {
+				failed = make(map[string]error)
+			}
+			failed[id] = errs[i]
+			continue
+		}
+		deleted = append(deleted, id)
+	}
+	return deleted, failed
+}
+
+// DeleteUsers is like Client.DeleteUsers, using a one-shot client of
+// sock.
+func DeleteUsers(sock string, ids []string) (deleted []string, failed map[string]error) {
+	return oneShotClient(sock).DeleteUsers(ids)
+}
+
+// FetchMetrics send http GET request to the given metrics path
+// (e.g. /metrics or /debug/vars) and return the raw
+// response body without decoding it, so it can be handed to whatever
+// understands the format (Prometheus text, expvar JSON, ...).
+//
+// The path must start with a slash. Expect a 2xx status, any other
+// status is returned as an *APIError.
+func (c *Client) FetchMetrics(path string) ([]byte, error) {
+	// The path is appended to the host as is, so without a leading
+	// slash it would become part of the host name instead.
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("metrics path %q must start with /", path)
+	}
+
+	// Send the http request to the server.
 	// For UDS-based HTTP, the domain in the URL
 	// is not important and is ignored here with
 	// an underscore (_).
-	req, err := http.NewRequest(http.MethodPost, "http://_/api/v1/user", &buf)
+	req, err := http.NewRequest(metricsEndpoint.method, "http://_"+path, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Content-Type", "application/json")
-
-	// Send the http request to the server.
-	resp, err := client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
-	// Reading and parsing the response body.
+	// Reading the response body as is.
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode == http.StatusCreated {
-		// If the request is successful,
-		// return the user information.
-		var data CreateUserResponse
-		err = json.Unmarshal(body, &data)
-		if err != nil {
-			return nil, err
-		}
-		return &data, nil
-	} else {
+	if !metricsEndpoint.succeeded(resp.StatusCode) {
+		return nil, responseError(resp, body)
+	}
+	return body, nil
+}
+
+// FetchMetrics is like Client.FetchMetrics, using a one-shot client
+// of sock.
+func FetchMetrics(sock, path string) ([]byte, error) {
+	return oneShotClient(sock).FetchMetrics(path)
+}
+
+// StreamUsers is a streaming variant of GetUsers. It sends http GET
+// request to /api/v1/users endpoint and calls fn with each user name
+// as soon as it has been decoded, instead of collecting the whole
+// list first, so a long list can be processed without holding it in
+// memory.
+//
+// Null entries are dropped, as by GetUsers. If fn returns an error,
+// the rest of the response is not read and the error is returned as
+// is. If ctx is canceled meanwhile, ctx.Err() is returned. A body that
+// does not decode is a *DecodeError, and a stream the server reports
+// as incomplete a *StreamError.
+func (c *Client) StreamUsers(ctx context.Context, fn func(name string) error) error {
+	// Create the request bound to ctx, so canceling ctx
+	// also aborts the round trip.
+	req, err := http.NewRequestWithContext(ctx, getUsersEndpoint.method, getUsersEndpoint.url(), nil)
+	if err != nil {
+		return err
+	}
+
+	// Send the http request to the server.
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !getUsersEndpoint.succeeded(resp.StatusCode) {
 		// If it fails, return the "msg" in the
 		// response body.
-		var data errorResponse
-		err = json.Unmarshal(body, &data)
+		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		return responseError(resp, body)
+	}
+
+	if !c.lenientContentType {
+		if err := checkJSONContentType(resp); err != nil {
+			return err
+		}
+	}
+
+	// Decode the array one element at a time, keeping the start of
+	// the body for the DecodeError, if any.
+	r := &recordingReader{r: resp.Body, max: maxDecodeErrorBodyLen}
+	dec := json.NewDecoder(r)
+	fail := func(err error) error {
+		// A canceled ctx makes reading the body fail too,
+		// report the cancellation rather than the read error.
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// A body cut short by the server fails to decode too,
+		// but the trailer tells why.
+		io.Copy(io.Discard, resp.Body)
+		if streamErr := streamError(resp); streamErr != nil {
+			return streamErr
+		}
+
+		// A failed read is not the fault of the body, it is
+		// returned as is.
+		if r.err != nil {
+			return err
+		}
+		return &DecodeError{Body: r.buf, Err: err}
+	}
+	tok, err := dec.Token()
+	if err != nil {
+		return fail(err)
+	}
+	if tok != json.Delim('[') {
+		return fail(fmt.Errorf("want a JSON array, got %v", tok))
+	}
+	skipped := 0
+	for dec.More() {
+		// Null entries are dropped, as by GetUsers.
+		var name *string
+		if err := dec.Decode(c.decodeTarget(&name)); err != nil {
+			return fail(err)
+		}
+		if name == nil {
+			skipped++
+			continue
+		}
+		if err := fn(*name); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return fail(err)
+	}
+	c.recordSkipped(skipped)
+
+	// Drain what the decoder leaves behind, so the trailers are
+	// received.
+	io.Copy(io.Discard, resp.Body)
+	return streamError(resp)
+}
+
+// StreamUsers is like Client.StreamUsers, using a one-shot client of
+// sock.
+func StreamUsers(ctx context.Context, sock string, fn func(name string) error) error {
+	return oneShotClient(sock).StreamUsers(ctx, fn)
+}
+
+// GetUsersChan is a streaming variant of GetUsers. It sends http GET
+// request to /api/v1/users endpoint and delivers each user name over
+// the returned channel as soon as it has been decoded, instead of
+// collecting the whole list first.
+//
+// Both channels are closed once the response has been consumed. At
+// most one error is sent on the error channel. If ctx is canceled
+// before the consumer has read every name, the response body is
+// closed and ctx.Err() is reported.
+func (c *Client) GetUsersChan(ctx context.Context) (<-chan string, <-chan error) {
+	names := make(chan string)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(names)
+
+		err := c.StreamUsers(ctx, func(name string) error {
+			select {
+			case names <- name:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errc <- err
+		}
+	}()
+
+	return names, errc
+}
+
+// GetUsersChan is like Client.GetUsersChan, using a one-shot client
+// of sock.
+func GetUsersChan(ctx context.Context, sock string) (<-chan string, <-chan error) {
+	return oneShotClient(sock).GetUsersChan(ctx)
+}
+
+// LoginRequest is the payload of Login.
+type LoginRequest struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// Login send http POST request to /api/v1/login endpoint of the
+// socket to open a session for user. The server answers with a
+// session cookie, which the Client keeps and sends with the requests
+// that follow. See WithCookieJar.
+//
+// Payload format:
+//
+//	{
+//		"user": "jack",
+//		"password": "secret"
+//	}
+//
+// Expect 200 OK or 204 No Content. If it is not, e.g. 401
+// Unauthorized for a wrong password, it will return an *APIError.
+func (c *Client) Login(user, pass string) error {
+	return c.LoginContext(context.Background(), user, pass)
+}
+
+// LoginContext is like Login, but the request is bound to ctx.
+func (c *Client) LoginContext(ctx context.Context, user, pass string) error {
+	payload := LoginRequest{User: user, Password: pass}
+	return c.send(ctx, loginEndpoint, loginEndpoint.url(), payload, nil)
+}
+
+// Login is like Client.Login, using a one-shot client of sock. The
+// session cookie goes away with that client, so it only checks the
+// credentials; use a Client to keep the session for later calls.
+func Login(sock, user, pass string) error {
+	return oneShotClient(sock).Login(user, pass)
+}
+
+// HealthCheck send http GET request to /healthz endpoint of the
+// socket to check that the server is up and serving. It returns nil
+// on 200 OK, an *APIError for any other status, or the dial error
+// when the socket does not accept connections.
+func (c *Client) HealthCheck() error {
+	return c.HealthCheckContext(context.Background())
+}
+
+// HealthCheckContext is like HealthCheck, but the request is bound to
+// ctx.
+func (c *Client) HealthCheckContext(ctx context.Context) error {
+	// The body, e.g. {"status": "ok"}, is not needed.
+	return c.send(ctx, healthEndpoint, healthEndpoint.url(), nil, nil)
+}
+
+// HealthCheck is like Client.HealthCheck, using a one-shot client of
+// sock.
+func HealthCheck(sock string) error {
+	return oneShotClient(sock).HealthCheck()
+}
+
+// WaitReady calls HealthCheck on sock until it succeeds, waiting
+// interval between the tries, e.g. to wait for a daemon started along
+// with the caller to be up. If ctx is done first, the error of the
+// last try is returned, or the error of ctx if there was none.
+func WaitReady(ctx context.Context, sock string, interval time.Duration) error {
+	client := oneShotClient(sock)
+	var lastErr error
+	for {
+		err := client.HealthCheckContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		// A try cut short by ctx tells nothing new, keep the
+		// error of the one before.
+		if ctx.Err() == nil {
+			lastErr = err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if lastErr == nil {
+				return ctx.Err()
+			}
+			return lastErr
+		case <-timer.C:
+		}
+	}
+}
+
+// Ping checks that sock accepts connections, without speaking HTTP:
+// it dials the socket, bound to ctx, and closes the connection right
+// away. It is cheaper than HealthCheck, and succeeds as soon as the
+// server listens, even if its HTTP layer is not up yet. A failed dial
+// is returned as a *ConnectError.
+func Ping(ctx context.Context, sock string) error {
+	conn, err := dialSocket(ctx, sock)
+	if err != nil {
+		return &ConnectError{Sock: sock, Err: err}
+	}
+	return conn.Close()
+}
+
+// Reachable probes every socket in socks concurrently and returns the
+// first one that accepts a connection. The probes that are still in
+// flight are canceled as soon as a winner is found. If none of the
+// sockets can be reached before ctx is done, the last dial error is
+// returned.
+func Reachable(ctx context.Context, socks []string) (string, error) {
+	if len(socks) == 0 {
+		return "", errors.New("no socket to probe")
+	}
+
+	// Canceling ctx on return aborts the dials of the losers.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type probe struct {
+		sock string
+		err  error
+	}
+	probes := make(chan probe, len(socks))
+	for _, sock := range socks {
+		go func(sock string) {
+			probes <- probe{sock: sock, err: Ping(ctx, sock)}
+		}(sock)
+	}
+
+	var err error
+	for range socks {
+		p := <-probes
+		if p.err == nil {
+			return p.sock, nil
 		}
-		return nil, errors.New(data.Msg)
+		err = p.err
 	}
+	return "", err
 }
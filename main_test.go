@@ -1,13 +1,25 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -15,18 +27,22 @@ import (
 // NewUnixDomainSocketServer starts and returns a new Server based
 // on unix domain socket. The caller should call Close when finished,
 // to shut it down and delete the socket file.
+//
+// Every server gets a socket of its own, so tests can run in parallel
+// and a socket left behind by a crashed run does not get in the way.
 func NewUnixDomainSocketServer(handler http.Handler) *httptest.Server {
-	// Use a non-existent socket file to create a UDS connection.
-	sockPath := "dummy.sock"
-	l, err := net.Listen("unix", sockPath)
-	if err != nil {
-		panic(fmt.Sprintf("httptest: failed to listen on unix domain socket %v: %v", sockPath, err))
-	}
+	return NewUnixDomainSocketServerWithListener(listenTempUnix(), handler)
+}
 
+// NewUnixDomainSocketServerWithListener is like
+// NewUnixDomainSocketServer, but serves on l instead of a listener of
+// its own, e.g. one wrapping listenTempUnix to count or filter the
+// connections. Closing the server closes l.
+func NewUnixDomainSocketServerWithListener(l net.Listener, handler http.Handler) *httptest.Server {
 	// Create a UDS-based mock http server.
 	ts := &httptest.Server{
 		Listener: l,
-		Config:   &http.Server{Handler: handler},
+		Config:   &http.Server{Handler: jsonByDefault(handler)},
 	}
 
 	// Run the server.
@@ -35,6 +51,64 @@ func NewUnixDomainSocketServer(handler http.Handler) *httptest.Server {
 	return ts
 }
 
+// NewUnixDomainSocketServerWithMode is like NewUnixDomainSocketServer,
+// but the socket file is given mode, e.g. 0600 to keep the other
+// users of a shared machine from connecting, as a permission-restricted
+// daemon would.
+func NewUnixDomainSocketServerWithMode(handler http.Handler, mode os.FileMode) *httptest.Server {
+	l := listenTempUnix()
+	if err := os.Chmod(l.Addr().String(), mode); err != nil {
+		l.Close()
+		panic(fmt.Sprintf("httptest: failed to change the mode of the unix domain socket: %v", err))
+	}
+	return NewUnixDomainSocketServerWithListener(l, handler)
+}
+
+// jsonByDefault makes the responses of handler application/json, as
+// are those of the API server, unless handler sets another
+// Content-Type.
+func jsonByDefault(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// listenTempUnix listens on a socket file in a new temporary
+// directory. Closing the listener removes the directory along with
+// the socket file.
+func listenTempUnix() net.Listener {
+	dir, err := os.MkdirTemp("", "uds-test-")
+	if err != nil {
+		panic(fmt.Sprintf("httptest: failed to create a directory for the unix domain socket: %v", err))
+	}
+
+	// Use a non-existent socket file to create a UDS connection.
+	sockPath := filepath.Join(dir, "dummy.sock")
+	if err := checkSockPath(sockPath); err != nil {
+		os.RemoveAll(dir)
+		panic(fmt.Sprintf("httptest: cannot listen on unix domain socket %v: %v", sockPath, err))
+	}
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		panic(fmt.Sprintf("httptest: failed to listen on unix domain socket %v: %v", sockPath, err))
+	}
+	return &tempDirListener{Listener: l, dir: dir}
+}
+
+// tempDirListener is a net.Listener removing dir when it is closed.
+type tempDirListener struct {
+	net.Listener
+	dir string
+}
+
+func (l *tempDirListener) Close() error {
+	err := l.Listener.Close()
+	os.RemoveAll(l.dir)
+	return err
+}
+
 func TestGetUsers(t *testing.T) {
 	t.Run("happy path, we can get users info", func(t *testing.T) {
 		// Create a router that routes http requests to specific handlers.
@@ -79,8 +153,8 @@ func TestGetUsers(t *testing.T) {
 		defer fakeServer.Close()
 
 		// The format of the URL from the UDS-based mock http server is
-		// 'http://dummy.sock', we only need the part after '//', i.e.
-		// 'dummy.sock'.
+		// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+		// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
 		sock := strings.Split(fakeServer.URL, "//")[1]
 
 		// Calling a function to be tested.
@@ -133,16 +207,122 @@ func TestGetUsers(t *testing.T) {
 		defer fakeServer.Close()
 
 		// The format of the URL from the UDS-based mock http server is
-		// 'http://dummy.sock', we only need the part after '//', i.e.
-		// 'dummy.sock'.
+		// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+		// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
 		sock := strings.Split(fakeServer.URL, "//")[1]
 
 		// Calling a function to be tested.
 		_, err := GetUsers(sock)
 
 		// Test the results of the function as we expect.
-		assert.Error(t, err)
-		assert.EqualError(t, err, "get error")
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+		assert.Equal(t, "get error", apiErr.Msg)
+	})
+
+	t.Run("unhappy path, API server redirects to itself", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// Count how many times the endpoint has been requested.
+		var calls int
+
+		// A misconfigured server that always redirects /api/v1/users
+		// back to /api/v1/users.
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			http.Redirect(w, r, "/api/v1/users", http.StatusFound)
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		// The format of the URL from the UDS-based mock http server is
+		// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+		// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Calling a function to be tested.
+		_, err := GetUsers(sock)
+
+		// The loop should be cut off after the redirect limit,
+		// i.e. the original request plus defaultMaxRedirects redirects.
+		assert.ErrorIs(t, err, ErrTooManyRedirects)
+		assert.Equal(t, defaultMaxRedirects+1, calls)
+	})
+
+	t.Run("unhappy path, context is canceled mid-request", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// The handler tells us the request has arrived, then hangs
+		// until the client gives up on it.
+		started := make(chan struct{})
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-r.Context().Done()
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		// The format of the URL from the UDS-based mock http server is
+		// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+		// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Cancel the context once the server is handling the request.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-started
+			cancel()
+		}()
+
+		// Calling a function to be tested.
+		_, err := GetUsersContext(ctx, sock)
+
+		// Test the results of the function as we expect.
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("happy path, null entries are skipped", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// The list holds a null among the names.
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`["Jack", null, "Sandy"]`))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1])
+
+		// Calling a function to be tested.
+		users, err := client.GetUsers()
+
+		// The valid names come back, and the null is counted.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack", "Sandy"}, users)
+		assert.Equal(t, 1, client.LastStats().SkippedUsers)
 	})
 }
 
@@ -197,8 +377,8 @@ func TestCreateUser(t *testing.T) {
 		defer fakeServer.Close()
 
 		// The format of the URL from the UDS-based mock http server is
-		// 'http://dummy.sock', we only need the part after '//', i.e.
-		// 'dummy.sock'.
+		// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+		// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
 		sock := strings.Split(fakeServer.URL, "//")[1]
 
 		// Calling a function to be tested.
@@ -258,15 +438,2317 @@ func TestCreateUser(t *testing.T) {
 		defer fakeServer.Close()
 
 		// The format of the URL from the UDS-based mock http server is
-		// 'http://dummy.sock', we only need the part after '//', i.e.
-		// 'dummy.sock'.
+		// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+		// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
 		sock := strings.Split(fakeServer.URL, "//")[1]
 
 		// Calling a function to be tested.
 		_, err := CreateUser(sock, "Jack")
 
 		// Test the results of the function as we expect.
-		assert.Error(t, err)
-		assert.EqualError(t, err, "get error")
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+		assert.Equal(t, "get error", apiErr.Msg)
+	})
+
+	t.Run("unhappy path, context is canceled mid-request", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// The handler tells us the request has arrived, then hangs
+		// until the client gives up on it.
+		started := make(chan struct{})
+		router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+			// Drain the payload first, the server only notices the
+			// client going away once the request body is consumed.
+			io.Copy(io.Discard, r.Body)
+			close(started)
+			<-r.Context().Done()
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		// The format of the URL from the UDS-based mock http server is
+		// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+		// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Cancel the context once the server is handling the request.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-started
+			cancel()
+		}()
+
+		// Calling a function to be tested.
+		_, err := CreateUserContext(ctx, sock, "Jack")
+
+		// Test the results of the function as we expect.
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+	t.Run("unhappy path, empty user name", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// The request must be rejected before it is sent.
+		router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+			t.Error("unexpected request")
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		for _, name := range []string{"", " \t\n"} {
+			// Calling a function to be tested.
+			user, err := CreateUser(sock, name)
+
+			// Test the results of the function as we expect.
+			assert.Nil(t, user)
+			assert.ErrorIs(t, err, ErrEmptyUserName)
+		}
+	})
+}
+
+func TestCreateUserEmptyBody(t *testing.T) {
+	// newServer starts a server answering 201 Created without a
+	// body, with location as the Location header if not empty, and
+	// returns its socket.
+	newServer := func(t *testing.T, location string) string {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+		router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+			if location != "" {
+				w.Header().Set("Location", location)
+			}
+			w.WriteHeader(http.StatusCreated)
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		t.Cleanup(fakeServer.Close)
+
+		// The format of the URL from the UDS-based mock http server is
+		// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+		// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+		return strings.Split(fakeServer.URL, "//")[1]
+	}
+
+	t.Run("happy path, the id comes from the Location", func(t *testing.T) {
+		sock := newServer(t, "/api/v1/user/ABC-111")
+
+		// Calling a function to be tested.
+		user, err := CreateUser(sock, "Jack")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Location: "/api/v1/user/ABC-111"}, user)
+	})
+
+	t.Run("happy path, no Location", func(t *testing.T) {
+		sock := newServer(t, "")
+
+		// Calling a function to be tested.
+		user, err := CreateUser(sock, "Jack")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{}, user)
+	})
+}
+
+func TestCreateUserLocation(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The server tells where the user is in the Location header,
+	// unless it is asked to create "Nobody".
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		var req CreateUserRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Name != "Nobody" {
+			w.Header().Set("Location", "/api/v1/user/id_foo")
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, the server sends the Location", func(t *testing.T) {
+		user, err := CreateUser(sock, "Jack")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "id_foo", Name: "Jack", Location: "/api/v1/user/id_foo"}, user)
+	})
+
+	t.Run("happy path, the server sends no Location", func(t *testing.T) {
+		user, err := CreateUser(sock, "Nobody")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "id_foo", Name: "Jack"}, user)
+	})
+}
+func TestFetchMetrics(t *testing.T) {
+	// A Prometheus text format body, which is not JSON at all.
+	metrics := "# HELP http_requests_total The total number of HTTP requests.\n" +
+		"# TYPE http_requests_total counter\n" +
+		"http_requests_total{method=\"get\",code=\"200\"} 1027\n"
+
+	t.Run("happy path, we get the raw metrics body", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// We expect to have the mock http server process /metrics
+		// and return the metrics in Prometheus text format.
+		router.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			// We expect the http method is GET.
+			assert.Equal(t, http.MethodGet, r.Method)
+
+			// return 200 OK and the metrics.
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(metrics))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		// The format of the URL from the UDS-based mock http server is
+		// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+		// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Calling a function to be tested.
+		body, err := FetchMetrics(sock, "/metrics")
+
+		// The body should come back byte for byte.
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(metrics), body)
+	})
+
+	t.Run("happy path, any 2xx status is accepted", func(t *testing.T) {
+		// Some exporters answer with 203 Non-Authoritative Information
+		// when the metrics are served from a cache.
+		router := http.NewServeMux()
+		router.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNonAuthoritativeInfo)
+			w.Write([]byte(metrics))
+		})
+		fakeServer := NewUnixDomainSocketServer(router)
+		defer fakeServer.Close()
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Calling a function to be tested.
+		body, err := FetchMetrics(sock, "/metrics")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(metrics), body)
+	})
+
+	t.Run("unhappy path, metrics path does not exist", func(t *testing.T) {
+		// An empty router answers every path with 404 Not Found.
+		fakeServer := NewUnixDomainSocketServer(http.NewServeMux())
+		defer fakeServer.Close()
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Calling a function to be tested.
+		_, err := FetchMetrics(sock, "/metrics")
+
+		// Test the results of the function as we expect.
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal(t, "404 page not found", apiErr.Msg)
+	})
+
+	t.Run("unhappy path, metrics path is relative", func(t *testing.T) {
+		// The handler fails the test if it is ever reached.
+		router := http.NewServeMux()
+		router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		})
+		fakeServer := NewUnixDomainSocketServer(router)
+		defer fakeServer.Close()
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Calling a function to be tested.
+		_, err := FetchMetrics(sock, "metrics")
+
+		// The path is rejected before anything is sent.
+		assert.EqualError(t, err, `metrics path "metrics" must start with /`)
+	})
+}
+
+func TestGetUsersChan(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// We expect to have the mock http server process /api/v1/users
+	// while faking its response as we expect it to look.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		// We expect the http method is GET.
+		assert.Equal(t, http.MethodGet, r.Method)
+
+		// return 200 OK and users info.
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[
+			"Jack",
+			"Marry",
+			"Sandy"
+		]`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock http server is
+	// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, we receive every user", func(t *testing.T) {
+		// Calling a function to be tested.
+		names, errc := GetUsersChan(context.Background(), sock)
+
+		// Collect the names until the channel is closed.
+		var users []string
+		for name := range names {
+			users = append(users, name)
+		}
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, <-errc)
+		assert.Equal(t, []string{"Jack", "Marry", "Sandy"}, users)
+	})
+
+	t.Run("unhappy path, consumer cancels after the first user", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Calling a function to be tested.
+		names, errc := GetUsersChan(ctx, sock)
+
+		// Take only the first name, then give up.
+		assert.Equal(t, "Jack", <-names)
+		cancel()
+
+		// The producer should stop and report the cancellation.
+		assert.ErrorIs(t, <-errc, context.Canceled)
+
+		// The names channel should be closed without delivering
+		// the rest of the list.
+		_, ok := <-names
+		assert.False(t, ok)
+	})
+}
+
+func TestStreamUsers(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// We expect to have the mock http server process /api/v1/users
+	// while faking its response as we expect it to look.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		// We expect the http method is GET.
+		assert.Equal(t, http.MethodGet, r.Method)
+
+		// return 200 OK and users info.
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack", "Marry", "Sandy"]`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock http server is
+	// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, fn is called for every user", func(t *testing.T) {
+		var users []string
+
+		// Calling a function to be tested.
+		err := StreamUsers(context.Background(), sock, func(name string) error {
+			users = append(users, name)
+			return nil
+		})
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack", "Marry", "Sandy"}, users)
+	})
+
+	t.Run("unhappy path, fn stops after the second user", func(t *testing.T) {
+		errStop := errors.New("stop")
+		var users []string
+
+		// Calling a function to be tested.
+		err := StreamUsers(context.Background(), sock, func(name string) error {
+			users = append(users, name)
+			if len(users) == 2 {
+				return errStop
+			}
+			return nil
+		})
+
+		// The error of fn is returned as is, and the third user
+		// is never delivered.
+		assert.Equal(t, errStop, err)
+		assert.Equal(t, []string{"Jack", "Marry"}, users)
+	})
+
+	// newServer starts a server answering body, then setting the
+	// X-Stream-Error trailer to streamErr if not empty, and returns
+	// its socket.
+	newServer := func(t *testing.T, body, streamErr string) string {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Trailer", "X-Stream-Error")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(body))
+			if streamErr != "" {
+				w.Header().Set("X-Stream-Error", streamErr)
+			}
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		t.Cleanup(fakeServer.Close)
+
+		// The format of the URL from the UDS-based mock http server is
+		// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+		// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+		return strings.Split(fakeServer.URL, "//")[1]
+	}
+
+	// collect is a fn keeping the names in users.
+	collect := func(users *[]string) func(name string) error {
+		return func(name string) error {
+			*users = append(*users, name)
+			return nil
+		}
+	}
+
+	t.Run("happy path, null entries are dropped", func(t *testing.T) {
+		sock := newServer(t, `["Jack", null, "Sandy"]`, "")
+		var users []string
+
+		// Calling a function to be tested.
+		err := StreamUsers(context.Background(), sock, collect(&users))
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack", "Sandy"}, users)
+	})
+
+	t.Run("unhappy path, an entry is not a string", func(t *testing.T) {
+		sock := newServer(t, `["Jack", 42, "Sandy"]`, "")
+		var users []string
+
+		// Calling a function to be tested.
+		err := StreamUsers(context.Background(), sock, collect(&users))
+
+		// Test the results of the function as we expect.
+		var decodeErr *DecodeError
+		assert.ErrorAs(t, err, &decodeErr)
+		assert.Equal(t, []string{"Jack"}, users)
+	})
+
+	t.Run("unhappy path, the trailer reports an incomplete stream", func(t *testing.T) {
+		sock := newServer(t, `["Jack"]`, "database went away")
+		var users []string
+
+		// Calling a function to be tested.
+		err := StreamUsers(context.Background(), sock, collect(&users))
+
+		// Test the results of the function as we expect.
+		var streamErr *StreamError
+		if assert.ErrorAs(t, err, &streamErr) {
+			assert.Equal(t, "database went away", streamErr.Msg)
+		}
+		assert.Equal(t, []string{"Jack"}, users)
+	})
+
+	t.Run("unhappy path, the stream is cut short", func(t *testing.T) {
+		sock := newServer(t, `["Jack", "Ma`, "database went away")
+		var users []string
+
+		// Calling a function to be tested.
+		err := StreamUsers(context.Background(), sock, collect(&users))
+
+		// The trailer tells why the body does not decode.
+		var streamErr *StreamError
+		assert.ErrorAs(t, err, &streamErr)
+	})
+}
+
+func TestReachable(t *testing.T) {
+	t.Run("happy path, the reachable socket is picked", func(t *testing.T) {
+		// Create an UDS-based http server, the handler does not
+		// matter since only the connection is probed.
+		fakeServer := NewUnixDomainSocketServer(http.NewServeMux())
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		// The format of the URL from the UDS-based mock http server is
+		// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+		// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		// Calling a function to be tested.
+		got, err := Reachable(ctx, []string{"non-existent.sock", sock})
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, sock, got)
+	})
+
+	t.Run("unhappy path, no socket is reachable", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		// Calling a function to be tested.
+		_, err := Reachable(ctx, []string{"non-existent.sock"})
+
+		// Test the results of the function as we expect.
+		assert.Error(t, err)
+	})
+}
+
+func TestHandleResponse(t *testing.T) {
+	// newResponse fakes a response with the given status and body.
+	newResponse := func(status int, body string) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	t.Run("single-status endpoint", func(t *testing.T) {
+		e := endpoint{status: http.StatusCreated}
+
+		// The expected status is decoded into out.
+		var data CreateUserResponse
+		_, err := handleResponse(e, newResponse(http.StatusCreated, `{"id": "id_foo", "name": "name_foo"}`), &data)
+		assert.NoError(t, err)
+		assert.Equal(t, CreateUserResponse{ID: "id_foo", Name: "name_foo"}, data)
+
+		// Any other status, even a 2xx one, is a failure.
+		_, err = handleResponse(e, newResponse(http.StatusOK, `{"msg": "not created"}`), &data)
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, &APIError{StatusCode: http.StatusOK, Msg: "not created"}, apiErr)
+	})
+
+	t.Run("predicate-based endpoint", func(t *testing.T) {
+		e := endpoint{
+			// status is ignored once accept is set.
+			status: http.StatusOK,
+			accept: func(status int) bool {
+				return status == http.StatusOK || status == http.StatusAccepted
+			},
+		}
+
+		// Every accepted status is decoded into out.
+		for _, status := range []int{http.StatusOK, http.StatusAccepted} {
+			var data []string
+			_, err := handleResponse(e, newResponse(status, `["Jack"]`), &data)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"Jack"}, data)
+		}
+
+		// A status the predicate rejects is a failure.
+		var data []string
+		_, err := handleResponse(e, newResponse(http.StatusNoContent, `{"msg": "unexpected"}`), &data)
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, &APIError{StatusCode: http.StatusNoContent, Msg: "unexpected"}, apiErr)
+	})
+}
+
+func TestDeleteUser(t *testing.T) {
+	t.Run("happy path, the user is deleted", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// We expect to have the mock http server process
+		// /api/v1/user/{id} for the user to delete.
+		router.HandleFunc("/api/v1/user/ABC-111", func(w http.ResponseWriter, r *http.Request) {
+			// We expect the http method is DELETE.
+			assert.Equal(t, http.MethodDelete, r.Method)
+
+			// return 204 No Content.
+			w.WriteHeader(http.StatusNoContent)
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		// The format of the URL from the UDS-based mock http server is
+		// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+		// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Calling a function to be tested.
+		err := DeleteUser(sock, "ABC-111")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+	})
+
+	t.Run("unhappy path, the user does not exist", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// return 404 Not Found for any user.
+		router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodDelete, r.Method)
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"msg": "user not found"}`))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+		defer fakeServer.Close()
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Calling a function to be tested.
+		err := DeleteUser(sock, "XYZ-999")
+
+		// Test the results of the function as we expect.
+		var apiErr *APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+			assert.Equal(t, "user not found", apiErr.Msg)
+		}
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+}
+
+func TestGetUsersConcurrent(t *testing.T) {
+	t.Run("happy path, the users are collected by id", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// return the user for any id but XYZ-999, which is not found.
+		router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+			id := strings.TrimPrefix(r.URL.Path, "/api/v1/user/")
+			if id == "XYZ-999" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"msg": "user not found"}`))
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id": "` + id + `", "name": "name of ` + id + `"}`))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Calling a function to be tested.
+		users, err := GetUsersConcurrent(context.Background(), sock, []string{"ABC-111", "XYZ-999", "ABC-222"}, 2)
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]*CreateUserResponse{
+			"ABC-111": {ID: "ABC-111", Name: "name of ABC-111"},
+			"ABC-222": {ID: "ABC-222", Name: "name of ABC-222"},
+		}, users)
+	})
+
+	t.Run("happy path, no more requests in flight than the concurrency", func(t *testing.T) {
+		router := http.NewServeMux()
+
+		// Hold every request a moment, recording the most in flight.
+		var inFlight, maxInFlight int32
+		router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id": "ABC-111", "name": "Jack"}`))
+		})
+
+		fakeServer := NewUnixDomainSocketServer(router)
+		defer fakeServer.Close()
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1])
+		defer client.Close()
+
+		ids := []string{"ABC-1", "ABC-2", "ABC-3", "ABC-4", "ABC-5", "ABC-6", "ABC-7"}
+		users, err := client.GetUsersConcurrent(context.Background(), ids, 3)
+
+		assert.NoError(t, err)
+		assert.Len(t, users, len(ids))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&maxInFlight))
+	})
+
+	t.Run("unhappy path, the context is canceled mid-flight", func(t *testing.T) {
+		router := http.NewServeMux()
+
+		// Block every request until the client gives up on it.
+		arrived := make(chan struct{}, 10)
+		router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+			arrived <- struct{}{}
+			<-r.Context().Done()
+		})
+
+		fakeServer := NewUnixDomainSocketServer(router)
+		defer fakeServer.Close()
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Cancel once the first request reached the server.
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-arrived
+			cancel()
+		}()
+
+		users, err := GetUsersConcurrent(ctx, sock, []string{"ABC-1", "ABC-2", "ABC-3", "ABC-4", "ABC-5"}, 2)
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, users)
+
+		// The ids not handed out yet were never requested.
+		assert.LessOrEqual(t, len(arrived), 1)
+	})
+
+	t.Run("unhappy path, a server error cancels the siblings", func(t *testing.T) {
+		router := http.NewServeMux()
+
+		// Fail ERR-500 once ABC-1 is in flight too, so the
+		// cancellation cannot abort ABC-1 before it is sent, and
+		// block the others until canceled.
+		var requests int32
+		abc1 := make(chan struct{})
+		router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			if strings.HasSuffix(r.URL.Path, "/ABC-1") {
+				close(abc1)
+			}
+			if strings.HasSuffix(r.URL.Path, "/ERR-500") {
+				select {
+				case <-abc1:
+				case <-r.Context().Done():
+					return
+				}
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"msg": "database is down"}`))
+				return
+			}
+			<-r.Context().Done()
+		})
+
+		fakeServer := NewUnixDomainSocketServer(router)
+		defer fakeServer.Close()
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		users, err := GetUsersConcurrent(context.Background(), sock, []string{"ABC-1", "ERR-500", "ABC-3", "ABC-4", "ABC-5"}, 2)
+
+		var apiErr *APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+		}
+		assert.Nil(t, users)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("unhappy path, invalid concurrency", func(t *testing.T) {
+		_, err := GetUsersConcurrent(context.Background(), "/nonexistent.sock", []string{"ABC-1"}, 0)
+
+		assert.EqualError(t, err, "invalid concurrency 0")
+	})
+}
+
+func TestDeleteUsers(t *testing.T) {
+	t.Run("happy path, the existing users are deleted, the others reported", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// return 404 Not Found for XYZ-999, 500 Internal Server Error
+		// for ERR-500 and 204 No Content for the other users.
+		var mu sync.Mutex
+		calls := map[string]int{}
+		router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodDelete, r.Method)
+			id := strings.TrimPrefix(r.URL.Path, "/api/v1/user/")
+			mu.Lock()
+			calls[id]++
+			mu.Unlock()
+
+			switch id {
+			case "XYZ-999":
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"msg": "user not found"}`))
+			case "ERR-500":
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"msg": "database is down"}`))
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Calling a function to be tested.
+		deleted, failed := DeleteUsers(sock, []string{"ABC-111", "XYZ-999", "ABC-222", "ERR-500", "ABC-333", "ABC-111"})
+
+		// Test the results of the function as we expect.
+		assert.Equal(t, []string{"ABC-111", "ABC-222", "ABC-333"}, deleted)
+		assert.Len(t, failed, 2)
+		assert.ErrorIs(t, failed["XYZ-999"], ErrUserNotFound)
+		var apiErr *APIError
+		if assert.ErrorAs(t, failed["ERR-500"], &apiErr) {
+			assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+			assert.Equal(t, "database is down", apiErr.Msg)
+		}
+		assert.NotErrorIs(t, failed["ERR-500"], ErrUserNotFound)
+
+		// The duplicated id is deleted once.
+		assert.Equal(t, 1, calls["ABC-111"])
+	})
+
+	t.Run("happy path, no more requests in flight than workers", func(t *testing.T) {
+		router := http.NewServeMux()
+
+		// Hold every request a moment, recording the most in flight.
+		var inFlight, maxInFlight int32
+		router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			w.WriteHeader(http.StatusNoContent)
+		})
+
+		fakeServer := NewUnixDomainSocketServer(router)
+		defer fakeServer.Close()
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithBulkWorkers(2))
+		defer client.Close()
+
+		ids := []string{"ABC-1", "ABC-2", "ABC-3", "ABC-4", "ABC-5", "ABC-6"}
+		deleted, failed := client.DeleteUsers(ids)
+
+		assert.Equal(t, ids, deleted)
+		assert.Nil(t, failed)
+		assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(2))
+	})
+
+	t.Run("happy path, nothing to delete", func(t *testing.T) {
+		// No server is needed, no request is sent.
+		deleted, failed := DeleteUsers("/nonexistent.sock", nil)
+
+		assert.Empty(t, deleted)
+		assert.Nil(t, failed)
+	})
+}
+
+func TestGetUser(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// We expect to have the mock http server process /api/v1/user/{id},
+	// only ABC-111 exists.
+	router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+		// We expect the http method is GET.
+		assert.Equal(t, http.MethodGet, r.Method)
+
+		if r.URL.Path != "/api/v1/user/ABC-111" {
+			// return 404 Not Found.
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"msg": "user not found"}`))
+			return
+		}
+
+		// return 200 OK and user info.
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{
+			"id": "ABC-111",
+			"name": "Jack"
+		}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock http server is
+	// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, we can get the user", func(t *testing.T) {
+		// Calling a function to be tested.
+		user, err := GetUser(sock, "ABC-111")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Name: "Jack"}, user)
+	})
+
+	t.Run("unhappy path, the user does not exist", func(t *testing.T) {
+		// Calling a function to be tested.
+		_, err := GetUser(sock, "XYZ-999")
+
+		// Test the results of the function as we expect.
+		var apiErr *APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+			assert.Equal(t, "user not found", apiErr.Msg)
+		}
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+}
+
+func TestGetUserList(t *testing.T) {
+	t.Run("happy path, users come with their ids", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// We expect to have the mock http server process
+		// /api/v1/users/full while faking its response as we expect
+		// it to look.
+		router.HandleFunc("/api/v1/users/full", func(w http.ResponseWriter, r *http.Request) {
+			// We expect the http method is GET.
+			assert.Equal(t, http.MethodGet, r.Method)
+
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`[
+				{"id": "id_foo", "name": "Jack"},
+				{"id": "id_bar", "name": "Marry", "email": "marry@example.com"}
+			]`))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		// Calling a function to be tested.
+		users, err := GetUserList(strings.Split(fakeServer.URL, "//")[1])
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []CreateUserResponse{
+			{ID: "id_foo", Name: "Jack"},
+			{ID: "id_bar", Name: "Marry", Email: "marry@example.com"},
+		}, users)
+	})
+	t.Run("unhappy path, some error occur", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+		router.HandleFunc("/api/v1/users/full", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"msg": "something wrong!"}`))
+		})
+		fakeServer := NewUnixDomainSocketServer(router)
+		defer fakeServer.Close()
+
+		// Calling a function to be tested.
+		users, err := GetUserList(strings.Split(fakeServer.URL, "//")[1])
+
+		// Test the results of the function as we expect.
+		assert.EqualError(t, err, "api error (500): something wrong!")
+		assert.Nil(t, users)
+	})
+}
+
+func TestGetUsersByIDs(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler answers with the known users among the asked ids,
+	// like the fake_server does.
+	known := map[string]string{"id_foo": "Jack", "id_bar": "Marry"}
+	var asked []string
+	router.HandleFunc("/api/v1/users/by-id", func(w http.ResponseWriter, r *http.Request) {
+		// We expect the http method is GET.
+		assert.Equal(t, http.MethodGet, r.Method)
+
+		asked = r.URL.Query()["id"]
+		users := []CreateUserResponse{}
+		for _, id := range asked {
+			if name, ok := known[id]; ok {
+				users = append(users, CreateUserResponse{ID: id, Name: name})
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(users)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, known and unknown ids", func(t *testing.T) {
+		// Calling a function to be tested.
+		users, err := GetUsersByIDs(sock, []string{"id_foo", "id_unknown", "id_bar", "id_foo"})
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []CreateUserResponse{
+			{ID: "id_foo", Name: "Jack"},
+			{ID: "id_bar", Name: "Marry"},
+		}, users)
+
+		// Every id is only asked once.
+		assert.Equal(t, []string{"id_foo", "id_unknown", "id_bar"}, asked)
+	})
+
+	t.Run("happy path, only unknown ids", func(t *testing.T) {
+		users, err := GetUsersByIDs(sock, []string{"id_unknown"})
+
+		assert.NoError(t, err)
+		assert.Empty(t, users)
+	})
+
+	t.Run("happy path, no id", func(t *testing.T) {
+		asked = nil
+
+		users, err := GetUsersByIDs(sock, nil)
+
+		// Nothing is sent.
+		assert.NoError(t, err)
+		assert.Empty(t, users)
+		assert.Nil(t, asked)
+	})
+}
+
+func TestUpdateUser(t *testing.T) {
+	t.Run("happy path, the user is renamed", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// We expect to have the mock http server process
+		// /api/v1/user/{id} for the user to rename.
+		router.HandleFunc("/api/v1/user/ABC-111", func(w http.ResponseWriter, r *http.Request) {
+			// We expect the http method is PUT.
+			assert.Equal(t, http.MethodPut, r.Method)
+
+			// Check if the Content-Type header is application/json.
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+			// Check the payload format of the request.
+			body, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			assert.JSONEq(t, `{"name": "Sandy"}`, string(body))
+
+			// return 200 OK and the updated user info.
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{
+				"id": "ABC-111",
+				"name": "Sandy"
+			}`))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		// The format of the URL from the UDS-based mock http server is
+		// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+		// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Calling a function to be tested.
+		user, err := UpdateUser(sock, "ABC-111", "Sandy")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Name: "Sandy"}, user)
+	})
+
+	t.Run("unhappy path, API server has some problems", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// return 500 Internal Server Error.
+		router.HandleFunc("/api/v1/user/ABC-111", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"msg": "update error"}`))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+		defer fakeServer.Close()
+		sock := strings.Split(fakeServer.URL, "//")[1]
+
+		// Calling a function to be tested.
+		_, err := UpdateUser(sock, "ABC-111", "Sandy")
+
+		// Test the results of the function as we expect.
+		assert.EqualError(t, err, "api error (500): update error")
+	})
+}
+
+func TestUpdateUserIfMatch(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The mock http server keeps a version of the user, sent as its
+	// ETag, and renames it only if the If-Match is still current.
+	var mu sync.Mutex
+	name, version := "Jack", 1
+	etag := func() string { return fmt.Sprintf(`"v%d"`, version) }
+	router.HandleFunc("/api/v1/user/ABC-111", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method == http.MethodPut {
+			if match := r.Header.Get("If-Match"); match != "" && match != etag() {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				w.Write([]byte(`{"msg": "user was modified"}`))
+				return
+			}
+			var req CreateUserRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			name = req.Name
+			version++
+		}
+
+		w.Header().Set("ETag", etag())
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id": "ABC-111", "name": "` + name + `"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	// GetUser exposes the ETag to round-trip.
+	user, err := GetUser(sock, "ABC-111")
+	assert.NoError(t, err)
+	assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Name: "Jack", ETag: `"v1"`}, user)
+
+	t.Run("happy path, the etag is current", func(t *testing.T) {
+		// Calling a function to be tested.
+		updated, err := UpdateUserIfMatch(sock, "ABC-111", "Sandy", user.ETag)
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Name: "Sandy", ETag: `"v2"`}, updated)
+	})
+
+	t.Run("unhappy path, the etag is stale", func(t *testing.T) {
+		// Calling a function to be tested.
+		_, err := UpdateUserIfMatch(sock, "ABC-111", "Marry", user.ETag)
+
+		// Test the results of the function as we expect.
+		assert.ErrorIs(t, err, ErrPreconditionFailed)
+		var apiErr *APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusPreconditionFailed, apiErr.StatusCode)
+			assert.Equal(t, "user was modified", apiErr.Msg)
+		}
+		mu.Lock()
+		assert.Equal(t, "Sandy", name)
+		mu.Unlock()
+	})
+
+	t.Run("happy path, UpdateUser sends no If-Match", func(t *testing.T) {
+		updated, err := UpdateUser(sock, "ABC-111", "Marry")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Name: "Marry", ETag: `"v3"`}, updated)
+	})
+}
+
+func TestGetUsersRaw(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The body of the response, kept to compare with what the
+	// function returns.
+	body := `[
+		"Jack",
+		"Marry",
+		"Sandy"
+	]`
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(body))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock http server is
+	// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, users come with the raw body", func(t *testing.T) {
+		// Calling a function to be tested.
+		users, raw, err := GetUsersRaw(sock)
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack", "Marry", "Sandy"}, users)
+		assert.Equal(t, body, string(raw))
+	})
+
+	t.Run("unhappy path, the raw body survives a decode error", func(t *testing.T) {
+		body = `["Jack", 42]`
+
+		// Calling a function to be tested.
+		_, raw, err := GetUsersRaw(sock)
+
+		// Test the results of the function as we expect.
+		assert.Error(t, err)
+		assert.Equal(t, body, string(raw))
+	})
+}
+
+func TestGetUsersPage(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler pages through a fixed list of users, like the
+	// fake_server does.
+	users := []string{"Jack", "Marry", "Sandy"}
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+
+		start := (page - 1) * pageSize
+		if start > len(users) {
+			start = len(users)
+		}
+		end := start + pageSize
+		if end > len(users) {
+			end = len(users)
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"users": users[start:end],
+			"total": len(users),
+		})
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock http server is
+	// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, walk two pages", func(t *testing.T) {
+		// Calling a function to be tested.
+		page1, total, err := GetUsersPage(sock, 1, 2)
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack", "Marry"}, page1)
+		assert.Equal(t, int64(3), total)
+
+		// The second page holds the rest.
+		page2, total, err := GetUsersPage(sock, 2, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Sandy"}, page2)
+		assert.Equal(t, int64(3), total)
+	})
+
+	t.Run("unhappy path, invalid page", func(t *testing.T) {
+		// Calling a function to be tested.
+		_, _, err := GetUsersPage(sock, 0, 2)
+
+		// The request is rejected before it is sent.
+		assert.EqualError(t, err, "invalid page 0 of size 2")
+	})
+}
+
+func TestGetUsersPageLargeTotal(t *testing.T) {
+	// 2^53 + 1 is the first integer a float64 cannot hold.
+	const total = "9007199254740993"
+
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"users": ["Jack"], "total": ` + total + `}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	// Calling a function to be tested.
+	users, got, err := GetUsersPage(sock, 1, 1)
+
+	// The total is decoded as an integer, not through a float64, so
+	// it comes back exactly.
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Jack"}, users)
+	assert.Equal(t, total, strconv.FormatInt(got, 10))
+}
+
+func TestCreateUsers(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The response of the handler, changed by each subtest.
+	var status int
+	var body string
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		// The names are POSTed as a json array.
+		payload, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `[{"name": "Jack"}, {"name": "Marry"}]`, string(payload))
+
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock http server is
+	// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, all users are created", func(t *testing.T) {
+		status = http.StatusCreated
+		body = `[
+			{"id": "ABC-111", "name": "Jack"},
+			{"id": "ABC-112", "name": "Marry"}
+		]`
+
+		// Calling a function to be tested.
+		users, err := CreateUsers(sock, []string{"Jack", "Marry"})
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []CreateUserResponse{
+			{ID: "ABC-111", Name: "Jack"},
+			{ID: "ABC-112", Name: "Marry"},
+		}, users)
+	})
+
+	t.Run("unhappy path, some users are not created", func(t *testing.T) {
+		status = http.StatusMultiStatus
+		body = `[
+			{"id": "ABC-111", "name": "Jack", "status": 201},
+			{"name": "Marry", "status": 409, "msg": "user already exists"}
+		]`
+
+		// Calling a function to be tested.
+		users, err := CreateUsers(sock, []string{"Jack", "Marry"})
+
+		// The created user is returned along with the failed one.
+		assert.Equal(t, []CreateUserResponse{{ID: "ABC-111", Name: "Jack"}}, users)
+		var batchErr *BatchError
+		if assert.ErrorAs(t, err, &batchErr) {
+			assert.Equal(t, []BatchFailure{{
+				Name: "Marry",
+				Err:  &APIError{StatusCode: http.StatusConflict, Msg: "user already exists"},
+			}}, batchErr.Failures)
+		}
+		assert.EqualError(t, err, `users not created: "Marry": api error (409): user already exists`)
+	})
+
+	t.Run("unhappy path, the whole batch is rejected", func(t *testing.T) {
+		status = http.StatusBadRequest
+		body = `{"msg": "bad batch"}`
+
+		// Calling a function to be tested.
+		users, err := CreateUsers(sock, []string{"Jack", "Marry"})
+
+		// Test the results of the function as we expect.
+		assert.Nil(t, users)
+		assert.EqualError(t, err, "api error (400): bad batch")
+	})
+}
+
+func TestCreateUsersChunked(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler creates every user of a batch, numbering them in
+	// the order they arrive, and remembers the size of each batch.
+	var batches []int
+	var created int
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		var req []CreateUserRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		assert.NoError(t, err)
+		batches = append(batches, len(req))
+
+		// A user named "Bad" makes the whole batch fail.
+		resp := make([]CreateUserResponse, len(req))
+		for i, user := range req {
+			if user.Name == "Bad" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"msg": "bad name"}`))
+				return
+			}
+			created++
+			resp[i] = CreateUserResponse{ID: fmt.Sprintf("ABC-%d", created), Name: user.Name}
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock http server is
+	// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, more users than one chunk holds", func(t *testing.T) {
+		batches, created = nil, 0
+
+		// Calling a function to be tested.
+		users, err := CreateUsersChunked(sock, []string{"Jack", "Marry", "Sandy", "Tom", "Amy"}, 2)
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []int{2, 2, 1}, batches)
+		assert.Equal(t, []CreateUserResponse{
+			{ID: "ABC-1", Name: "Jack"},
+			{ID: "ABC-2", Name: "Marry"},
+			{ID: "ABC-3", Name: "Sandy"},
+			{ID: "ABC-4", Name: "Tom"},
+			{ID: "ABC-5", Name: "Amy"},
+		}, users)
+	})
+
+	t.Run("unhappy path, stop at the first failed chunk", func(t *testing.T) {
+		batches, created = nil, 0
+
+		// Calling a function to be tested.
+		users, err := CreateUsersChunked(sock, []string{"Jack", "Marry", "Bad", "Tom", "Amy"}, 2)
+
+		// The first chunk is kept, the last one is never sent.
+		assert.EqualError(t, err, "api error (400): bad name")
+		assert.Equal(t, []int{2, 2}, batches)
+		assert.Equal(t, []CreateUserResponse{
+			{ID: "ABC-1", Name: "Jack"},
+			{ID: "ABC-2", Name: "Marry"},
+		}, users)
+	})
+}
+
+func TestLogin(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// We expect to have the mock http server process /api/v1/login
+	// and set the session cookie on success.
+	router.HandleFunc("/api/v1/login", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		var req LoginRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.User != "jack" || req.Password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"msg": "wrong user or password"}`))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/"})
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Echo the session cookie back as the only user, if any.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		session := ""
+		if cookie, err := r.Cookie("session"); err == nil {
+			session = cookie.Value
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode([]string{session})
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, the session cookie is sent back", func(t *testing.T) {
+		client := NewClient(sock)
+		defer client.Close()
+
+		// Calling functions to be tested.
+		err := client.Login("jack", "secret")
+		assert.NoError(t, err)
+		users, err := client.GetUsers()
+
+		// Test the results of the functions as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"abc123"}, users)
+
+		// Another Client has a session of its own.
+		users, err = NewClient(sock).GetUsers()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{""}, users)
+	})
+
+	t.Run("happy path, a shared jar shares the session", func(t *testing.T) {
+		jar := newCookieJar()
+		assert.NoError(t, NewClient(sock, WithCookieJar(jar)).Login("jack", "secret"))
+
+		users, err := NewClient(sock, WithCookieJar(jar)).GetUsers()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"abc123"}, users)
+	})
+
+	t.Run("happy path, a nil jar keeps no cookie", func(t *testing.T) {
+		client := NewClient(sock, WithCookieJar(nil))
+		assert.NoError(t, client.Login("jack", "secret"))
+
+		users, err := client.GetUsers()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{""}, users)
+	})
+
+	t.Run("unhappy path, wrong password", func(t *testing.T) {
+		// Calling a function to be tested.
+		err := Login(sock, "jack", "guess")
+
+		// Test the results of the function as we expect.
+		var apiErr *APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+			assert.Equal(t, "wrong user or password", apiErr.Msg)
+		}
+	})
+}
+
+func TestHealthCheck(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The status of the server, changed by each subtest.
+	status := http.StatusOK
+	router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"status": "ok"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock http server is
+	// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, the server is up", func(t *testing.T) {
+		status = http.StatusOK
+
+		// Calling a function to be tested.
+		err := HealthCheck(sock)
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+	})
+
+	t.Run("unhappy path, the server is unavailable", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+
+		// Calling a function to be tested.
+		err := HealthCheck(sock)
+
+		// Test the results of the function as we expect.
+		var apiErr *APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+		}
+	})
+
+	t.Run("unhappy path, the socket file is missing", func(t *testing.T) {
+		// Calling a function to be tested.
+		err := HealthCheck("missing.sock")
+
+		// Nothing listens there, the dial fails.
+		assert.Error(t, err)
+	})
+}
+
+func BenchmarkGetUsers(b *testing.B) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// A long list of users, encoded once.
+	users := make([]string, 10000)
+	for i := range users {
+		users[i] = fmt.Sprintf("user-%05d", i)
+	}
+	body, _ := json.Marshal(users)
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1])
+
+	// GetUsers decodes the body as it streams in.
+	b.Run("decoder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := client.GetUsers(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// GetUsersRaw reads the whole body first, then decodes it.
+	b.Run("ReadAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := client.GetUsersRaw(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestCreateUserWithParams(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The payload the server saw last.
+	var payload string
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		payload = string(body)
+
+		// Echo the user back along with its id.
+		var user map[string]string
+		json.Unmarshal(body, &user)
+		user["id"] = "id_foo"
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(user)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock http server is
+	// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, every field is sent", func(t *testing.T) {
+		// Calling a function to be tested.
+		user, err := CreateUserWithParams(sock, CreateUserParams{
+			Name:  "Jack",
+			Email: "jack@example.com",
+			Role:  "admin",
+		})
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"name": "Jack", "email": "jack@example.com", "role": "admin"}`, payload)
+		assert.Equal(t, &CreateUserResponse{
+			ID:    "id_foo",
+			Name:  "Jack",
+			Email: "jack@example.com",
+			Role:  "admin",
+		}, user)
+	})
+
+	t.Run("happy path, unset fields are left out", func(t *testing.T) {
+		// Calling a function to be tested.
+		user, err := CreateUser(sock, "Jack")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"name": "Jack"}`, payload)
+		assert.Equal(t, &CreateUserResponse{ID: "id_foo", Name: "Jack"}, user)
+	})
+}
+
+func TestNewUnixDomainSocketServer(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Start two servers at the same time.
+	first := NewUnixDomainSocketServer(router)
+	second := NewUnixDomainSocketServer(router)
+
+	firstSock := strings.Split(first.URL, "//")[1]
+	secondSock := strings.Split(second.URL, "//")[1]
+
+	// They listen on distinct sockets, and both work.
+	assert.NotEqual(t, firstSock, secondSock)
+	for _, sock := range []string{firstSock, secondSock} {
+		users, err := GetUsers(sock)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+	}
+
+	// Closing a server cleans its socket up, along with its
+	// directory.
+	first.Close()
+	second.Close()
+	assert.NoDirExists(t, filepath.Dir(firstSock))
+	assert.NoDirExists(t, filepath.Dir(secondSock))
+}
+
+// countingListener is a net.Listener counting the connections it
+// accepts.
+type countingListener struct {
+	net.Listener
+	accepted int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(&l.accepted, 1)
+	}
+	return conn, err
+}
+
+func TestNewUnixDomainSocketServerWithListener(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Serve on a listener of our own that counts the connections.
+	l := &countingListener{Listener: listenTempUnix()}
+	fakeServer := NewUnixDomainSocketServerWithListener(l, router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	// Each one-shot client opens a connection of its own, while the
+	// calls of a Client share one.
+	for i := 0; i < 2; i++ {
+		_, err := GetUsers(sock)
+		assert.NoError(t, err)
+	}
+	client := NewClient(sock)
+	for i := 0; i < 2; i++ {
+		_, err := client.GetUsers()
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&l.accepted))
+}
+
+func TestNewUnixDomainSocketServerWithMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("socket file modes are not enforced on Windows")
+	}
+
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	for _, mode := range []os.FileMode{0600, 0660} {
+		t.Run(fmt.Sprintf("happy path, mode %#o", mode), func(t *testing.T) {
+			// Create an UDS-based http server and register the router
+			// with a predefined mock handler.
+			fakeServer := NewUnixDomainSocketServerWithMode(router, mode)
+
+			// We should always close the http server at the end of
+			// the test to release related resources and delete the
+			// socket file.
+			defer fakeServer.Close()
+			sock := strings.Split(fakeServer.URL, "//")[1]
+
+			// Test the results of the function as we expect.
+			info, err := os.Stat(sock)
+			if assert.NoError(t, err) {
+				assert.Equal(t, os.ModeSocket, info.Mode().Type())
+				assert.Equal(t, mode, info.Mode().Perm())
+			}
+
+			// The owner can still connect.
+			_, err = GetUsers(sock)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler filters a fixed list of users by the query, like
+	// the fake_server does.
+	users := []string{"Jack & Jill", "Marry", "Sandy"}
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		matched := []string{}
+		for _, user := range users {
+			if strings.Contains(user, q) {
+				matched = append(matched, user)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(matched)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock http server is
+	// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, the query is encoded", func(t *testing.T) {
+		// Calling a function to be tested. Sent as is, the space
+		// and the '&' would cut the query short.
+		matched, err := SearchUsers(sock, "k & J")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack & Jill"}, matched)
+	})
+
+	t.Run("happy path, nothing matches", func(t *testing.T) {
+		// Calling a function to be tested.
+		matched, err := SearchUsers(sock, "Tom")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Empty(t, matched)
+	})
+}
+
+func TestGetUsersWithPrefix(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler filters a fixed list of users by the prefix, like
+	// the fake_server does.
+	users := []string{"Jack & Jill", "Jackie", "Marry"}
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		matched := []string{}
+		for _, user := range users {
+			if strings.HasPrefix(user, r.URL.Query().Get("prefix")) {
+				matched = append(matched, user)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(matched)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock http server is
+	// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, the prefix filters the users", func(t *testing.T) {
+		// Calling a function to be tested.
+		matched, err := GetUsersWithPrefix(sock, "Jack")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack & Jill", "Jackie"}, matched)
+	})
+
+	t.Run("happy path, the prefix is encoded", func(t *testing.T) {
+		// Calling a function to be tested. Sent as is, the space
+		// and the '&' would cut the prefix short.
+		matched, err := GetUsersWithPrefix(sock, "Jack & J")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack & Jill"}, matched)
+	})
+
+	t.Run("happy path, an empty prefix returns every user", func(t *testing.T) {
+		// Calling a function to be tested.
+		matched, err := GetUsersWithPrefix(sock, "")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, users, matched)
+	})
+
+	t.Run("happy path, nothing matches", func(t *testing.T) {
+		// Calling a function to be tested.
+		matched, err := GetUsersWithPrefix(sock, "Tom")
+
+		// The slice is empty, but not nil.
+		assert.NoError(t, err)
+		assert.NotNil(t, matched)
+		assert.Empty(t, matched)
+	})
+}
+
+func TestPatchUser(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler merges the fields into the stored user, like the
+	// fake_server does.
+	router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+		// We expect the http method is PATCH, with only the
+		// fields to change.
+		assert.Equal(t, http.MethodPatch, r.Method)
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"name": "Marry"}`, string(body))
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id": "ABC-111", "name": "Marry", "email": "jack@example.com"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock http server is
+	// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, only the name is changed", func(t *testing.T) {
+		// Calling a function to be tested.
+		user, err := PatchUser(sock, "ABC-111", map[string]interface{}{"name": "Marry"})
+
+		// The other fields of the user are left as they were.
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Name: "Marry", Email: "jack@example.com"}, user)
+	})
+
+	t.Run("unhappy path, nothing to change", func(t *testing.T) {
+		// Calling a function to be tested. The handler would fail
+		// the test on an empty payload.
+		user, err := PatchUser(sock, "ABC-111", map[string]interface{}{})
+
+		// Test the results of the function as we expect.
+		assert.Nil(t, user)
+		assert.ErrorIs(t, err, ErrEmptyPatch)
+	})
+}
+
+func TestUpdateUserParams(t *testing.T) {
+	empty := ""
+	name := "Marry"
+
+	t.Run("happy path, a nil field is left out", func(t *testing.T) {
+		b, err := json.Marshal(UpdateUserParams{Name: &name})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"name": "Marry"}`, string(b))
+	})
+
+	t.Run("happy path, a field pointing to empty is sent", func(t *testing.T) {
+		b, err := json.Marshal(UpdateUserParams{Name: &name, Email: &empty})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"name": "Marry", "email": ""}`, string(b))
+	})
+}
+
+func TestPatchUserWithParams(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler merges the fields into the stored user, like the
+	// fake_server does.
+	router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		user := map[string]interface{}{"name": "Jack", "email": "jack@example.com"}
+		var fields map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&fields))
+		for key, value := range fields {
+			user[key] = value
+		}
+		user["id"] = "ABC-111"
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(user)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, a nil email is kept", func(t *testing.T) {
+		name := "Marry"
+
+		// Calling a function to be tested.
+		user, err := PatchUserWithParams(sock, "ABC-111", UpdateUserParams{Name: &name})
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Name: "Marry", Email: "jack@example.com"}, user)
+	})
+
+	t.Run("happy path, an empty email is cleared", func(t *testing.T) {
+		empty := ""
+
+		// Calling a function to be tested.
+		user, err := PatchUserWithParams(sock, "ABC-111", UpdateUserParams{Email: &empty})
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Name: "Jack"}, user)
+	})
+
+	t.Run("unhappy path, nothing to change", func(t *testing.T) {
+		// Calling a function to be tested.
+		user, err := PatchUserWithParams(sock, "ABC-111", UpdateUserParams{})
+
+		// Test the results of the function as we expect.
+		assert.Nil(t, user)
+		assert.ErrorIs(t, err, ErrEmptyPatch)
+	})
+}
+
+func TestWaitReady(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status": "ok"}`))
+	})
+
+	t.Run("happy path, the server starts after a while", func(t *testing.T) {
+		sock := filepath.Join(t.TempDir(), "late.sock")
+
+		// Start the server once WaitReady has tried a few times.
+		started := make(chan *httptest.Server, 1)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			l, err := net.Listen("unix", sock)
+			if !assert.NoError(t, err) {
+				close(started)
+				return
+			}
+			started <- NewUnixDomainSocketServerWithListener(l, router)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// Calling a function to be tested.
+		err := WaitReady(ctx, sock, 10*time.Millisecond)
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		if fakeServer := <-started; fakeServer != nil {
+			fakeServer.Close()
+		}
+	})
+
+	t.Run("unhappy path, the context expires first", func(t *testing.T) {
+		sock := filepath.Join(t.TempDir(), "never.sock")
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		// Calling a function to be tested.
+		err := WaitReady(ctx, sock, 10*time.Millisecond)
+
+		// The last failed try is reported, not only the deadline.
+		var connErr *ConnectError
+		if assert.ErrorAs(t, err, &connErr) {
+			assert.Equal(t, sock, connErr.Sock)
+		}
+		assert.NotErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("unhappy path, the context is already done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// Calling a function to be tested.
+		err := WaitReady(ctx, filepath.Join(t.TempDir(), "never.sock"), time.Second)
+
+		// Test the results of the function as we expect.
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestPing(t *testing.T) {
+	t.Run("happy path, the socket is live", func(t *testing.T) {
+		// Only a listener, nothing speaks HTTP on it.
+		l := listenTempUnix()
+		defer l.Close()
+
+		// Calling a function to be tested.
+		err := Ping(context.Background(), l.Addr().String())
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+	})
+
+	t.Run("unhappy path, the socket refuses connections", func(t *testing.T) {
+		// The socket file is left behind by a server that is gone.
+		sock := filepath.Join(t.TempDir(), "stale.sock")
+		l, err := net.ListenUnix("unix", &net.UnixAddr{Name: sock, Net: "unix"})
+		assert.NoError(t, err)
+		l.SetUnlinkOnClose(false)
+		l.Close()
+
+		// Calling a function to be tested.
+		err = Ping(context.Background(), sock)
+
+		// Test the results of the function as we expect.
+		var connErr *ConnectError
+		if assert.ErrorAs(t, err, &connErr) {
+			assert.Equal(t, sock, connErr.Sock)
+		}
+		assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+	})
+
+	t.Run("unhappy path, the context is already canceled", func(t *testing.T) {
+		l := listenTempUnix()
+		defer l.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// Calling a function to be tested.
+		err := Ping(ctx, l.Addr().String())
+
+		// The socket is not even dialed.
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestGetUsersSorted(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handler sorts a fixed list of users by name, like the
+	// fake_server does.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "name", r.URL.Query().Get("sort"))
+
+		users := []string{"Marry", "Jack", "Sandy"}
+		sort.Strings(users)
+		if r.URL.Query().Get("order") == "desc" {
+			sort.Sort(sort.Reverse(sort.StringSlice(users)))
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(users)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock http server is
+	// 'http:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, ascending", func(t *testing.T) {
+		// Calling a function to be tested.
+		users, err := GetUsersSorted(sock, "name", "asc")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack", "Marry", "Sandy"}, users)
+	})
+
+	t.Run("happy path, descending", func(t *testing.T) {
+		// Calling a function to be tested.
+		users, err := GetUsersSorted(sock, "name", "desc")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Sandy", "Marry", "Jack"}, users)
+	})
+
+	t.Run("unhappy path, invalid order", func(t *testing.T) {
+		// Calling a function to be tested.
+		_, err := GetUsersSorted("missing.sock", "name", "random")
+
+		// The order is rejected before dialing, or the dial would
+		// fail first.
+		assert.EqualError(t, err, `invalid sort order "random", want asc or desc`)
 	})
 }
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Metrics collects the latency of the calls of a Client, set
+// WithMetrics, e.g. to export it to Prometheus.
+type Metrics interface {
+	// ObserveRequest is called once per call, with the status of
+	// the response, or 0 if there was none, and the time from just
+	// before the request was sent to just after the response body
+	// was read.
+	ObserveRequest(method, path string, status int, dur time.Duration)
+}
+
+// WithMetrics makes the Client report the latency of every call to m.
+func WithMetrics(m Metrics) Option {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
+// observe reports the call to target of e, started at start, to the
+// Metrics of c, if any.
+func (c *Client) observe(e endpoint, target string, resp *http.Response, start time.Time) {
+	if c.metrics == nil {
+		return
+	}
+
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	path := target
+	if u, err := url.Parse(target); err == nil {
+		path = u.Path
+	}
+	c.metrics.ObserveRequest(e.method, path, status, time.Since(start))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// observation is a call to Metrics.ObserveRequest.
+type observation struct {
+	method string
+	path   string
+	status int
+	dur    time.Duration
+}
+
+// fakeCollector is a Metrics remembering every observation.
+type fakeCollector struct {
+	mu           sync.Mutex
+	observations []observation
+}
+
+func (m *fakeCollector) ObserveRequest(method, path string, status int, dur time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observations = append(m.observations, observation{method: method, path: path, status: status, dur: dur})
+}
+
+func TestWithMetrics(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handlers take a little time to answer.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	collector := &fakeCollector{}
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithMetrics(collector))
+
+	// Calling functions to be tested.
+	_, err := client.GetUsers()
+	assert.NoError(t, err)
+	_, err = client.CreateUser("Jack")
+	assert.NoError(t, err)
+
+	// One observation per call, lasting at least as long as the
+	// server took.
+	if assert.Len(t, collector.observations, 2) {
+		get, create := collector.observations[0], collector.observations[1]
+		assert.Equal(t, http.MethodGet, get.method)
+		assert.Equal(t, "/api/v1/users", get.path)
+		assert.Equal(t, http.StatusOK, get.status)
+		assert.Equal(t, http.MethodPost, create.method)
+		assert.Equal(t, "/api/v1/user", create.path)
+		assert.Equal(t, http.StatusCreated, create.status)
+		for _, o := range collector.observations {
+			assert.GreaterOrEqual(t, o.dur, 10*time.Millisecond)
+			assert.Less(t, o.dur, 5*time.Second)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option configures a Client created by NewClient.
+type Option func(*Client)
+
+// WithMaxRedirects sets how many redirects a request may follow
+// before failing with ErrTooManyRedirects. The default is 10.
+func WithMaxRedirects(n int) Option {
+	return func(c *Client) {
+		c.maxRedirects = n
+	}
+}
+
+// WithPrettyRequests indents the JSON request bodies, which is easier
+// to read when debugging or dumping the wire. It does not change what
+// the server decodes.
+func WithPrettyRequests(pretty bool) Option {
+	return func(c *Client) {
+		c.pretty = pretty
+	}
+}
+
+// WithPreflightCheck probes the server with HEAD /healthz before every
+// request and discards the pooled connections if the probe fails, so
+// a connection the server has dropped is not used for the request.
+// It trades one extra round trip for reliability.
+func WithPreflightCheck() Option {
+	return func(c *Client) {
+		c.preflight = true
+	}
+}
+
+// WithTimeout bounds how long a request may take, including reading
+// the response body. Zero, the default, means no timeout.
+//
+// The deadline of the ctx given to a Context method applies as well,
+// the shorter of the two wins: a ctx can cut a call short, but not
+// give it longer than d. Calls that legitimately take longer, e.g.
+// large batches, need a Client of their own with a longer timeout.
+// With WithRetry, d bounds each attempt, the ctx all of them.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithAutoUnwrap makes GetUser accept a user that the server wrapped
+// in a single-element array or in a {"data": {...}} envelope, as well
+// as the bare object.
+func WithAutoUnwrap() Option {
+	return func(c *Client) {
+		c.autoUnwrap = true
+	}
+}
+
+// WithHeader adds a header, e.g. Authorization, to every request sent
+// by the Client. Using it several times with the same key sends all
+// the values.
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		if c.headers == nil {
+			c.headers = make(http.Header)
+		}
+		c.headers.Add(key, value)
+	}
+}
+
+// WithMaxRequestBytes makes requests whose body is larger than n bytes
+// fail with ErrRequestTooLarge instead of being sent, e.g. to match
+// the limit of a server that would answer 413. Zero, the default,
+// means no limit.
+func WithMaxRequestBytes(n int64) Option {
+	return func(c *Client) {
+		c.maxRequestBytes = n
+	}
+}
+
+// WithMaxResponseBytes makes reading a response body larger than n
+// bytes fail with a *ResponseTooLargeError, rather than exhausting the
+// memory on a server sending without end. Zero, the default, means no
+// limit.
+func WithMaxResponseBytes(n int64) Option {
+	return func(c *Client) {
+		c.maxResponseBytes = n
+	}
+}
+
+// WithBulkWorkers sets how many requests DeleteUsers may have in
+// flight at once. The default is 4; values below 1 are taken as 1, to
+// delete one user after the other.
+func WithBulkWorkers(n int) Option {
+	return func(c *Client) {
+		if n < 1 {
+			n = 1
+		}
+		c.bulkWorkers = n
+	}
+}
+
+// WithTransport makes the Client use a copy of t, e.g. to tune
+// MaxIdleConns, MaxIdleConnsPerHost or IdleConnTimeout. Its
+// DialContext is replaced to dial the unix domain socket. Without it,
+// the Client uses a zero http.Transport.
+func WithTransport(t *http.Transport) Option {
+	return func(c *Client) {
+		c.transport = t
+	}
+}
+
+// WithCookieJar makes the Client keep the cookies set by the server
+// in jar, instead of in a jar of its own, e.g. to share a session
+// between Clients. A nil jar disables cookies.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(c *Client) {
+		c.jar = jar
+	}
+}
+
+// WithRoundTripper makes the Client send its requests through rt as
+// is, instead of its own transport, e.g. to add tracing or to answer
+// without a server in tests. It is up to rt to reach the socket: the
+// options configuring the transport, such as WithTransport,
+// WithDialContext or WithTLSConfig, are not applied to it. Responses
+// are still decompressed and recorded WithCassette.
+func WithRoundTripper(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.roundTripper = rt
+	}
+}
+
+// WithUserAgent sets the User-Agent header of every request sent by
+// the Client, instead of "uds-http-client/<version>".
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithHost sets the Host of every request sent by the Client, e.g.
+// for a reverse proxy behind the socket routing by virtual host.
+// Without it, the Host is "_", the host of the URLs.
+func WithHost(host string) Option {
+	return func(c *Client) {
+		c.host = host
+	}
+}
+
+// WithDisableKeepAlives makes the Client close every connection once
+// its response is read, instead of keeping it idle for the next
+// request, e.g. for a command line tool making a single call. By
+// default connections are pooled.
+func WithDisableKeepAlives(disable bool) Option {
+	return func(c *Client) {
+		c.disableKeepAlives = disable
+	}
+}
+
+// WithBasePath prepends path to the path of every request, e.g.
+// "/service-a" for a server mounting the API under
+// /service-a/api/v1. Slashes around path are not doubled nor missed,
+// "service-a/" works as well.
+func WithBasePath(path string) Option {
+	return func(c *Client) {
+		path = strings.Trim(path, "/")
+		if path == "" {
+			c.basePath = ""
+			return
+		}
+		c.basePath = "/" + path
+	}
+}
+
+// WithDialContext makes the Client connect with dial, called with the
+// "unix" network and the socket of the Client, e.g. to count the
+// connections or to inject latency or faults, wrapping a net.Dialer.
+// The TLS handshake, if any, is still done over the returned
+// connection, and a failure is still a *ConnectError.
+func WithDialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(c *Client) {
+		c.dial = func(ctx context.Context, sock string) (net.Conn, error) {
+			return dial(ctx, "unix", sock)
+		}
+	}
+}
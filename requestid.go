@@ -0,0 +1,28 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// requestIDHeader carries the ID correlating the logs of the client
+// and of the server for a request.
+const requestIDHeader = "X-Request-ID"
+
+// WithRequestIDGenerator makes the Client call gen for the
+// X-Request-ID of every request, instead of using a random one.
+// A retried request keeps its ID.
+func WithRequestIDGenerator(gen func() string) Option {
+	return func(c *Client) {
+		c.requestID = gen
+	}
+}
+
+// newRequestID returns a random 128-bit ID, hex encoded.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
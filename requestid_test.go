@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The request IDs the server saw, in order.
+	var ids []string
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get("X-Request-ID"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get("X-Request-ID"))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"msg": "create error"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("a random ID is sent by default", func(t *testing.T) {
+		ids = nil
+		client := NewClient(sock)
+
+		client.GetUsers()
+		client.GetUsers()
+
+		// Every request gets an ID of its own.
+		if assert.Len(t, ids, 2) {
+			assert.Len(t, ids[0], 32)
+			assert.NotEqual(t, ids[0], ids[1])
+		}
+		assert.Equal(t, ids[1], client.LastStats().RequestID)
+	})
+
+	t.Run("the generator is called per request", func(t *testing.T) {
+		ids = nil
+		n := 0
+		client := NewClient(sock, WithRequestIDGenerator(func() string {
+			n++
+			return strings.Repeat("a", n)
+		}))
+
+		_, err := client.GetUsers()
+		assert.NoError(t, err)
+		_, err = client.CreateUser("Jack")
+
+		assert.Equal(t, []string{"a", "aa"}, ids)
+
+		// The error tells which request failed.
+		var apiErr *APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, "aa", apiErr.RequestID)
+		}
+	})
+}
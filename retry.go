@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithRetry retries a failed request up to count times, waiting up to
+// backoff before the first retry and up to twice as long before each
+// next one. The wait is random ("full jitter"), so that clients
+// failing together do not all retry at once. Requests are retried
+// when dialing the socket fails, and idempotent ones (GET, HEAD, PUT,
+// DELETE, OPTIONS, or any with an Idempotency-Key) also on 502, 503
+// and 504. Another POST is never retried once it reached the server,
+// to avoid creating duplicates, unless it was turned down with 429.
+// The wait then follows the Retry-After of the response, if any.
+//
+// With WithCircuitBreaker, the retries stop once the circuit opens.
+func WithRetry(count int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retryCount = count
+		c.retryBackoff = backoff
+	}
+}
+
+// WithRetryJitterSource makes the Client draw the random waits between
+// retries from src, e.g. a rand.NewSource with a fixed seed for
+// reproducible tests. By default, a source shared by all the Clients
+// is used.
+func WithRetryJitterSource(src rand.Source) Option {
+	return func(c *Client) {
+		c.retryRand = &lockedRand{r: rand.New(src)}
+	}
+}
+
+// lockedRand is a *rand.Rand safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// retryRand is the source of the retry waits of the Clients not given
+// one WithRetryJitterSource.
+var retryRand = &lockedRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// jitter returns a random duration between 0 and d, both included.
+func (l *lockedRand) jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Duration(l.r.Int63n(int64(d) + 1))
+}
+
+// shouldRetry reports whether the attempt of req that ended with resp
+// or err is worth retrying.
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	// A body that cannot be sent again rules out any retry.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		// Nothing reached the server if the dial failed.
+		return isDialError(err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		// The request was turned down, not processed.
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(req)
+	}
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header value, either in
+// seconds or as an HTTP date, into how long to wait from now. It
+// reports false if value is empty or malformed.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	// A date in the past means the wait is already over.
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// isDialError reports whether err comes from connecting to the socket.
+func isDialError(err error) bool {
+	var connErr *ConnectError
+	return errors.As(err, &connErr)
+}
+
+// isIdempotent reports whether sending req twice has the same effect
+// as sending it once, by its method or its Idempotency-Key.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return req.Header.Get(idempotencyKeyHeader) != ""
+}
+
+// rewind returns a copy of req that can be sent again, with a fresh
+// body.
+func rewind(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRetry(t *testing.T) {
+	t.Run("GET is retried until the server recovers", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// The server fails twice, then succeeds.
+		var calls int32
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(`{"msg": "busy"}`))
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`["Jack"]`))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithRetry(3, time.Millisecond))
+
+		// Calling a function to be tested.
+		users, err := client.GetUsers()
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("POST is not retried once it reached the server", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// The server always fails.
+		var calls int32
+		router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"msg": "busy"}`))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+		defer fakeServer.Close()
+
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithRetry(3, time.Millisecond))
+
+		// Calling a function to be tested.
+		_, err := client.CreateUser("Jack")
+
+		// The user may have been created, so no second attempt.
+		assert.EqualError(t, err, "api error (503): busy")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("POST is retried when the dial fails", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+		router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+			// The payload is sent again on the retried attempt.
+			body, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			assert.JSONEq(t, `{"name": "Jack"}`, string(body))
+
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+		})
+
+		// The server only starts listening a little after the first
+		// attempt, so the first dials fail.
+		sock := filepath.Join(t.TempDir(), "late.sock")
+		started := make(chan *httptest.Server)
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			l, err := net.Listen("unix", sock)
+			if err != nil {
+				panic(err)
+			}
+			ts := &httptest.Server{
+				Listener: l,
+				Config:   &http.Server{Handler: jsonByDefault(router)},
+			}
+			ts.Start()
+			started <- ts
+		}()
+
+		// The waits are random, a fixed source makes sure they
+		// last long enough for the server to start.
+		client := NewClient(sock, WithRetry(6, 10*time.Millisecond), WithRetryJitterSource(rand.NewSource(1)))
+
+		// Calling a function to be tested.
+		user, err := client.CreateUser("Jack")
+		(<-started).Close()
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "id_foo", Name: "Jack"}, user)
+	})
+
+	t.Run("backoff is cut short by the context", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"msg": "busy"}`))
+		})
+		fakeServer := NewUnixDomainSocketServer(router)
+		defer fakeServer.Close()
+
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithRetry(3, time.Minute))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// Calling a function to be tested.
+		_, err := client.GetUsersContext(ctx)
+
+		// The call gives up instead of waiting a minute.
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("Retry-After of a 429 replaces the backoff", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// The server turns the first POST down, asking to come
+		// back right away.
+		var calls int32
+		router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"msg": "slow down"}`))
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+		})
+		fakeServer := NewUnixDomainSocketServer(router)
+		defer fakeServer.Close()
+
+		// The backoff alone would outlast the test.
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithRetry(1, time.Hour))
+
+		// Calling a function to be tested.
+		user, err := client.CreateUser("Jack")
+
+		// The POST was retried, it was not processed the first time.
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "id_foo", Name: "Jack"}, user)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestRetryJitter(t *testing.T) {
+	t.Run("waits are within the backoff", func(t *testing.T) {
+		jitter := &lockedRand{r: rand.New(rand.NewSource(1))}
+
+		for i := 0; i < 1000; i++ {
+			wait := jitter.jitter(time.Second)
+			assert.GreaterOrEqual(t, wait, time.Duration(0))
+			assert.LessOrEqual(t, wait, time.Second)
+		}
+		assert.Zero(t, jitter.jitter(0))
+	})
+
+	t.Run("retries wait as drawn from the source", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// The server always fails, and records when it was called.
+		var attempts []time.Time
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			attempts = append(attempts, time.Now())
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"msg": "busy"}`))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		backoff := 20 * time.Millisecond
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithRetry(3, backoff), WithRetryJitterSource(rand.NewSource(1)))
+
+		// Calling a function to be tested.
+		_, err := client.GetUsers()
+		assert.Error(t, err)
+
+		// The same source draws the same waits: each lasts at least
+		// as drawn, and not much longer.
+		expected := &lockedRand{r: rand.New(rand.NewSource(1))}
+		if assert.Len(t, attempts, 4) {
+			for i := 1; i < len(attempts); i++ {
+				wait := expected.jitter(backoff)
+				assert.LessOrEqual(t, wait, backoff)
+				gap := attempts[i].Sub(attempts[i-1])
+				assert.GreaterOrEqual(t, gap, wait)
+				assert.Less(t, gap, wait+50*time.Millisecond)
+				backoff *= 2
+			}
+		}
+	})
+}
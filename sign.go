@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// RequestSigner signs a request right before it is sent, e.g. by
+// setting an HMAC of its method, path and body in a header. req is
+// the request as it goes on the wire, and body is its body, nil if it
+// has none. An error fails the request without sending it.
+type RequestSigner func(req *http.Request, body []byte) error
+
+// WithRequestSigner makes the Client call signer on every request it
+// sends, including each retry, so a signature over a timestamp or a
+// nonce is fresh for every attempt.
+func WithRequestSigner(signer RequestSigner) Option {
+	return func(c *Client) {
+		c.signer = signer
+	}
+}
+
+// sign calls the RequestSigner of c, if any, on req. The body is read
+// to be given to the signer, then put back to be sent.
+func (c *Client) sign(req *http.Request) error {
+	if c.signer == nil {
+		return nil
+	}
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return c.signer(req, body)
+}
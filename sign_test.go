@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// signature is the HMAC of method, path and body that the daemon
+// expects in the X-Signature header.
+func signature(method, path string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(method + "\n" + path + "\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWithRequestSigner(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The handlers only answer requests signed as expected.
+	var requests atomic.Int32
+	verify := func(w http.ResponseWriter, r *http.Request) bool {
+		requests.Add(1)
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		if r.Header.Get("X-Signature") != signature(r.Method, r.URL.Path, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"msg": "bad signature"}`))
+			return false
+		}
+		return true
+	}
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		if verify(w, r) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`["Jack"]`))
+		}
+	})
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		if verify(w, r) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "ABC-111", "name": "Jack"}`))
+		}
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	client := NewClient(sock, WithRequestSigner(func(req *http.Request, body []byte) error {
+		req.Header.Set("X-Signature", signature(req.Method, req.URL.Path, body))
+		return nil
+	}))
+	defer client.Close()
+
+	t.Run("happy path, a request without a body is signed", func(t *testing.T) {
+		// Calling a function to be tested.
+		users, err := client.GetUsers()
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+	})
+
+	t.Run("happy path, the body is signed and still sent", func(t *testing.T) {
+		// Calling a function to be tested.
+		user, err := client.CreateUser("Jack")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "ABC-111", Name: "Jack"}, user)
+	})
+
+	t.Run("unhappy path, the request is not signed", func(t *testing.T) {
+		// Calling a function to be tested.
+		_, err := GetUsers(sock)
+
+		// Test the results of the function as we expect.
+		var apiErr *APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+		}
+	})
+
+	t.Run("unhappy path, the signer fails", func(t *testing.T) {
+		errNoKey := errors.New("no key")
+		client := NewClient(sock, WithRequestSigner(func(req *http.Request, body []byte) error {
+			return errNoKey
+		}))
+		before := requests.Load()
+
+		// Calling a function to be tested.
+		_, err := client.CreateUser("Jack")
+
+		// The request is not sent.
+		assert.ErrorIs(t, err, errNoKey)
+		assert.Equal(t, before, requests.Load())
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// defaultSock is the socket DefaultSocket falls back to, the one the
+// fake_server listens on by default.
+const defaultSock = "mysock.sock"
+
+// DefaultSocket returns the socket the API server listens on unless
+// told otherwise: the UDS_SOCK environment variable, as read by the
+// fake_server, or mysock.sock if it is not set. NewClient and the
+// package-level functions use it when given an empty sock, so a
+// relocated socket can be pointed to without changing the callers.
+func DefaultSocket() string {
+	if sock := os.Getenv("UDS_SOCK"); sock != "" {
+		return sock
+	}
+	return defaultSock
+}
+
+// ErrSockPathTooLong is returned, before dialing, when the socket path
+// does not fit in the sun_path of a unix socket address. The kernel
+// would otherwise reject it with a cryptic "invalid argument".
+var ErrSockPathTooLong = errors.New("socket path too long")
+
+// maxSockPathLen returns the longest socket path the platform
+// accepts, in bytes.
+func maxSockPathLen() int {
+	return maxSockPathLenOf(runtime.GOOS)
+}
+
+// maxSockPathLenOf returns the longest socket path goos accepts, in
+// bytes.
+func maxSockPathLenOf(goos string) int {
+	switch goos {
+	case "linux", "windows":
+		// sun_path is 108 bytes, the path need not be NUL
+		// terminated.
+		return 108
+	}
+
+	// sun_path is 104 bytes on darwin and the BSDs, NUL included.
+	return 103
+}
+
+// checkSockPath returns an error wrapping ErrSockPathTooLong if sock
+// is too long to be dialed or listened on.
+func checkSockPath(sock string) error {
+	if max := maxSockPathLen(); len(sock) > max {
+		return fmt.Errorf("%w (%d > %d bytes)", ErrSockPathTooLong, len(sock), max)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSockPathTooLong(t *testing.T) {
+	t.Run("unhappy path, the path is over the limit", func(t *testing.T) {
+		sock := "/tmp/" + strings.Repeat("x", maxSockPathLen()) + ".sock"
+		client := NewClient(sock)
+
+		// Calling a function to be tested.
+		_, err := client.GetUsers()
+
+		// The path is reported as is, not as a failed dial.
+		assert.ErrorIs(t, err, ErrSockPathTooLong)
+		assert.EqualError(t, err, fmt.Sprintf("socket path too long (%d > %d bytes)", len(sock), maxSockPathLen()))
+	})
+
+	t.Run("happy path, the path is at the limit", func(t *testing.T) {
+		sock := strings.Repeat("x", maxSockPathLen())
+		assert.NoError(t, checkSockPath(sock))
+	})
+}
+
+func TestMaxSockPathLenOf(t *testing.T) {
+	tests := []struct {
+		goos string
+		max  int
+	}{
+		{"linux", 108},
+		{"windows", 108},
+		{"darwin", 103},
+		{"freebsd", 103},
+		{"openbsd", 103},
+	}
+	for _, tt := range tests {
+		t.Run(tt.goos, func(t *testing.T) {
+			// Calling a function to be tested.
+			max := maxSockPathLenOf(tt.goos)
+
+			// Test the results of the function as we expect.
+			assert.Equal(t, tt.max, max)
+		})
+	}
+}
+
+func TestDefaultSocket(t *testing.T) {
+	t.Run("happy path, UDS_SOCK is set", func(t *testing.T) {
+		t.Setenv("UDS_SOCK", "/run/api/api.sock")
+
+		// Calling a function to be tested.
+		sock := DefaultSocket()
+
+		// Test the results of the function as we expect.
+		assert.Equal(t, "/run/api/api.sock", sock)
+	})
+
+	t.Run("happy path, UDS_SOCK is empty", func(t *testing.T) {
+		t.Setenv("UDS_SOCK", "")
+
+		assert.Equal(t, "mysock.sock", DefaultSocket())
+	})
+
+	t.Run("happy path, an empty sock dials UDS_SOCK", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`["Jack"]`))
+		})
+
+		// Create an UDS-based http server and register the router with a
+		// predefined mock handler.
+		fakeServer := NewUnixDomainSocketServer(router)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+		t.Setenv("UDS_SOCK", strings.Split(fakeServer.URL, "//")[1])
+
+		users, err := GetUsers("")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+	})
+}
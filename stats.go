@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Stats describes the last response received by a Client.
+type Stats struct {
+	// ConnectionClosedByServer reports whether the server asked
+	// to close the connection ("Connection: close"), so it was not
+	// put back into the pool and the next request dials again.
+	ConnectionClosedByServer bool
+
+	// ServerTimings holds the duration of every metric of the
+	// Server-Timing header, e.g. "db;dur=53" becomes
+	// ServerTimings["db"] == 53ms. Metrics without a duration are
+	// reported as 0. It is nil if the header was not sent.
+	ServerTimings map[string]time.Duration
+
+	// RequestID is the X-Request-ID the request was sent with.
+	RequestID string
+
+	// Header holds the headers of the response, e.g. ETag or
+	// X-RateLimit-Remaining. It is a copy, safe to keep.
+	Header http.Header
+
+	// SkippedUsers is the number of null entries dropped from the
+	// list of users of the response, if it was one.
+	SkippedUsers int
+}
+
+// LastStats returns the Stats of the last response received by c.
+// With concurrent calls it is the one that arrived last.
+func (c *Client) LastStats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastStats
+}
+
+// ClientStats counts the requests sent by a Client since it was
+// created.
+type ClientStats struct {
+	// Requests is the number of requests sent, or that failed
+	// before they could be, not counting the retries.
+	Requests int64
+
+	// Retries is the number of times a request was sent again,
+	// see WithRetry.
+	Retries int64
+
+	// Successes is the number of requests answered with a 2xx
+	// status.
+	Successes int64
+
+	// ClientErrors and ServerErrors are the numbers of requests
+	// answered with a 4xx and a 5xx status.
+	ClientErrors int64
+	ServerErrors int64
+
+	// Others is the number of requests answered with any other
+	// status, e.g. a 304 Not Modified or a redirect not followed.
+	// The outcomes add up to Requests.
+	Others int64
+
+	// Errors is the number of requests that got no response, e.g.
+	// the socket could not be dialed or the circuit breaker is
+	// open.
+	Errors int64
+}
+
+// Stats returns the counts of the requests sent by c so far. Each
+// count is read atomically, but a request in flight may be counted
+// in Requests and not yet in any outcome.
+func (c *Client) Stats() ClientStats {
+	return ClientStats{
+		Requests:     c.counters.requests.Load(),
+		Retries:      c.counters.retries.Load(),
+		Successes:    c.counters.successes.Load(),
+		ClientErrors: c.counters.clientErrors.Load(),
+		ServerErrors: c.counters.serverErrors.Load(),
+		Others:       c.counters.others.Load(),
+		Errors:       c.counters.errors.Load(),
+	}
+}
+
+// counters are the counts behind ClientStats, updated by do.
+type counters struct {
+	requests     atomic.Int64
+	retries      atomic.Int64
+	successes    atomic.Int64
+	clientErrors atomic.Int64
+	serverErrors atomic.Int64
+	others       atomic.Int64
+	errors       atomic.Int64
+}
+
+// record counts the outcome of a request, answered with resp, or
+// failed with err without a response.
+func (n *counters) record(resp *http.Response, err error) {
+	switch {
+	case resp == nil:
+		n.errors.Add(1)
+	case resp.StatusCode >= 500:
+		n.serverErrors.Add(1)
+	case resp.StatusCode >= 400:
+		n.clientErrors.Add(1)
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		n.successes.Add(1)
+	default:
+		n.others.Add(1)
+	}
+}
+
+// recordSkipped sets the SkippedUsers of the last Stats.
+func (c *Client) recordSkipped(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastStats.SkippedUsers = n
+}
+
+// parseServerTiming parses the Server-Timing header of h. Malformed
+// durations are ignored rather than failing the request.
+func parseServerTiming(h http.Header) map[string]time.Duration {
+	values := h.Values("Server-Timing")
+	if len(values) == 0 {
+		return nil
+	}
+
+	timings := make(map[string]time.Duration)
+	for _, value := range values {
+		// Each header may list several comma separated metrics.
+		for _, metric := range strings.Split(value, ",") {
+			params := strings.Split(metric, ";")
+			name := strings.TrimSpace(params[0])
+			if name == "" {
+				continue
+			}
+
+			var dur time.Duration
+			for _, param := range params[1:] {
+				key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || strings.TrimSpace(key) != "dur" {
+					continue
+				}
+				// The duration is in milliseconds and may
+				// have a fractional part.
+				ms, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+				if err == nil {
+					dur = time.Duration(ms * float64(time.Millisecond))
+				}
+			}
+			timings[name] = dur
+		}
+	}
+	return timings
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastStats(t *testing.T) {
+	t.Run("server closes the connection", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+
+		// The server answers and asks to close the connection.
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Connection", "close")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`["Jack"]`))
+		})
+
+		// Create an UDS-based http server that counts every
+		// connection it accepts.
+		var conns int32
+		fakeServer := newConnCountingServer(router, &conns)
+
+		// We should always close the http server at the end of the test
+		// to release related resources and delete the socket file.
+		defer fakeServer.Close()
+
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1])
+
+		// The body is still decoded as usual.
+		users, err := client.GetUsers()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+		assert.True(t, client.LastStats().ConnectionClosedByServer)
+
+		// The connection could not be reused, so the next request
+		// dials a new one.
+		_, err = client.GetUsers()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&conns))
+	})
+
+	t.Run("server keeps the connection", func(t *testing.T) {
+		// Create a router that routes http requests to specific handlers.
+		router := http.NewServeMux()
+		router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`["Jack"]`))
+		})
+		fakeServer := NewUnixDomainSocketServer(router)
+		defer fakeServer.Close()
+
+		client := NewClient(strings.Split(fakeServer.URL, "//")[1])
+
+		// Calling a function to be tested.
+		_, err := client.GetUsers()
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.False(t, client.LastStats().ConnectionClosedByServer)
+	})
+}
+
+func TestServerTimings(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The server reports how long its parts took.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Server-Timing", `db;dur=53, cache;desc="Cache Read";dur=23.2`)
+		w.Header().Add("Server-Timing", "miss")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1])
+
+	// Calling a function to be tested.
+	_, err := client.GetUsers()
+
+	// Test the results of the function as we expect.
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{
+		"db":    53 * time.Millisecond,
+		"cache": 23200 * time.Microsecond,
+		"miss":  0,
+	}, client.LastStats().ServerTimings)
+}
+
+func TestLastStatsHeader(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The server tells how many requests are left, and the version
+	// of what it sent.
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "41")
+		w.Header().Set("ETag", `"users-v1"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "40")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1])
+
+	// Calling functions to be tested.
+	_, err := client.GetUsers()
+	assert.NoError(t, err)
+	header := client.LastStats().Header
+	assert.Equal(t, "41", header.Get("X-RateLimit-Remaining"))
+	assert.Equal(t, `"users-v1"`, header.Get("ETag"))
+
+	// The headers are those of the last call only.
+	_, err = client.CreateUser("Jack")
+	assert.NoError(t, err)
+	assert.Equal(t, "40", client.LastStats().Header.Get("X-RateLimit-Remaining"))
+	assert.Empty(t, client.LastStats().Header.Get("ETag"))
+
+	// The headers kept from before are not changed.
+	assert.Equal(t, "41", header.Get("X-RateLimit-Remaining"))
+}
+
+func TestClientStats(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The users are listed after one 503, the user is created, and
+	// any other user is not found.
+	var calls int32
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"msg": "busy"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "id_foo", "name": "Jack"}`))
+	})
+	router.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"msg": "user not found"}`))
+	})
+	router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"msg": "database is down"}`))
+	})
+	router.HandleFunc("/api/v1/config", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	client := NewClient(strings.Split(fakeServer.URL, "//")[1], WithRetry(1, time.Millisecond))
+	assert.Equal(t, ClientStats{}, client.Stats())
+
+	// Calling functions to be tested.
+	_, err := client.GetUsers()
+	assert.NoError(t, err)
+	_, err = client.CreateUser("Jack")
+	assert.NoError(t, err)
+	_, err = client.GetUser("XYZ-999")
+	assert.Error(t, err)
+	assert.Error(t, client.HealthCheck())
+	_, err = client.Do(context.Background(), http.MethodGet, "/api/v1/config", nil, nil)
+	assert.Error(t, err)
+
+	// A call to a socket that is not there gets no response.
+	other := NewClient("/nonexistent/dummy.sock")
+	_, err = other.GetUsers()
+	assert.Error(t, err)
+
+	// Test the results of the functions as we expect. The 503 of
+	// GetUsers is retried, only its outcome is counted, and the 304
+	// is counted apart.
+	assert.Equal(t, ClientStats{
+		Requests:     5,
+		Retries:      1,
+		Successes:    2,
+		ClientErrors: 1,
+		ServerErrors: 1,
+		Others:       1,
+	}, client.Stats())
+	assert.Equal(t, ClientStats{Requests: 1, Errors: 1}, other.Stats())
+}
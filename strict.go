@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// WithStrict makes the Client fail to decode a user the server sent
+// with a field the client does not know, instead of ignoring it, e.g.
+// to notice in tests that the API changed. It is off by default.
+func WithStrict(strict bool) Option {
+	return func(c *Client) {
+		c.strict = strict
+	}
+}
+
+// strictJSON decodes into v, failing on unknown fields.
+type strictJSON struct {
+	v interface{}
+}
+
+func (s *strictJSON) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(s.v)
+}
+
+// userTarget returns what to decode a user into for it to end up in
+// v, which is strict if the Client is.
+func (c *Client) userTarget(v interface{}) interface{} {
+	if c.strict {
+		return &strictJSON{v: v}
+	}
+	return v
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithStrict(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+
+	// The server sends users with a field the client does not know.
+	router.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "id_foo", "name": "Jack", "nickname": "Jackie"}`))
+	})
+	router.HandleFunc("/api/v1/user/id_foo", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id": "id_foo", "name": "Jack", "nickname": "Jackie"}`))
+	})
+
+	// Create an UDS-based http server and register the router with a
+	// predefined mock handler.
+	fakeServer := NewUnixDomainSocketServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, lenient by default", func(t *testing.T) {
+		client := NewClient(sock)
+
+		user, err := client.CreateUser("Jack")
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "id_foo", Name: "Jack"}, user)
+
+		user, err = client.GetUser("id_foo")
+		assert.NoError(t, err)
+		assert.Equal(t, &CreateUserResponse{ID: "id_foo", Name: "Jack"}, user)
+	})
+
+	t.Run("unhappy path, strict", func(t *testing.T) {
+		client := NewClient(sock, WithStrict(true))
+
+		_, err := client.CreateUser("Jack")
+		assert.EqualError(t, err, `invalid response body: json: unknown field "nickname"`)
+
+		_, err = client.GetUser("id_foo")
+		assert.EqualError(t, err, `invalid response body: json: unknown field "nickname"`)
+	})
+
+	t.Run("unhappy path, strict with auto unwrap", func(t *testing.T) {
+		client := NewClient(sock, WithStrict(true), WithAutoUnwrap())
+
+		_, err := client.GetUser("id_foo")
+		assert.EqualError(t, err, `invalid response body: json: unknown field "nickname"`)
+	})
+}
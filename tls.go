@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// WithTLSConfig makes the Client speak TLS over the unix domain
+// socket, e.g. to a daemon requiring mutual TLS. URLs keep the
+// http://_ form, the TLS handshake runs right after dialing. As the
+// host of the URL means nothing, config should name the server in
+// ServerName, and carry the client certificate if the server asks
+// for one.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
+}
+
+// WithTLSHandshakeTimeout bounds how long the TLS handshake set up
+// WithTLSConfig may take, so a peer accepting the connection but
+// never completing the handshake fails the request fast. Zero, the
+// default, means no timeout.
+func WithTLSHandshakeTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.tlsHandshakeTimeout = d
+	}
+}
+
+// WithInsecureSkipVerify makes the Client accept any certificate the
+// server presents over the TLS set up WithTLSConfig, e.g. a self-signed
+// one in local development. It has no effect without WithTLSConfig.
+//
+// WARNING: the server is then not authenticated at all, and anyone
+// able to listen on the socket can impersonate it. Never use it in
+// production.
+func WithInsecureSkipVerify(skip bool) Option {
+	return func(c *Client) {
+		c.insecureSkipVerify = skip
+	}
+}
+
+// handshakeTLS runs the client side of a TLS handshake over conn,
+// bound to ctx and to timeout if not zero. conn is closed if the
+// handshake fails.
+func handshakeTLS(ctx context.Context, conn net.Conn, config *tls.Config, timeout time.Duration) (net.Conn, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		if errors.Is(err, context.DeadlineExceeded) && timeout > 0 {
+			return nil, fmt.Errorf("tls handshake timeout after %v: %w", timeout, err)
+		}
+		return nil, err
+	}
+	return tlsConn, nil
+}
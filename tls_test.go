@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// NewUnixDomainSocketTLSServer is like NewUnixDomainSocketServer, but
+// the server speaks TLS over the socket, with the self-signed
+// certificate of httptest, valid for "example.com".
+func NewUnixDomainSocketTLSServer(handler http.Handler) *httptest.Server {
+	ts := &httptest.Server{
+		Listener: listenTempUnix(),
+		Config:   &http.Server{Handler: jsonByDefault(handler)},
+	}
+
+	// Run the server.
+	ts.StartTLS()
+
+	return ts
+}
+
+// newSelfSignedCert returns a self-signed client certificate for cn.
+func newSelfSignedCert(t *testing.T, cn string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestWithTLSConfig(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		// The request came over TLS.
+		assert.NotNil(t, r.TLS)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Create an UDS-based https server and register the router with
+	// a predefined mock handler.
+	fakeServer := NewUnixDomainSocketTLSServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock https server is
+	// 'https:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, the server certificate is trusted", func(t *testing.T) {
+		roots := x509.NewCertPool()
+		roots.AddCert(fakeServer.Certificate())
+		client := NewClient(sock, WithTLSConfig(&tls.Config{
+			RootCAs:    roots,
+			ServerName: "example.com",
+		}))
+
+		// Calling a function to be tested.
+		users, err := client.GetUsers()
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+	})
+
+	t.Run("unhappy path, the server certificate is unknown", func(t *testing.T) {
+		client := NewClient(sock, WithTLSConfig(&tls.Config{
+			RootCAs:    x509.NewCertPool(),
+			ServerName: "example.com",
+		}))
+
+		// Calling a function to be tested.
+		_, err := client.GetUsers()
+
+		// The handshake fails, nothing is sent.
+		var unknownAuthority x509.UnknownAuthorityError
+		assert.ErrorAs(t, err, &unknownAuthority)
+	})
+
+	t.Run("unhappy path, plain http to a TLS server", func(t *testing.T) {
+		// Calling a function to be tested.
+		_, err := GetUsers(sock)
+
+		// Test the results of the function as we expect.
+		assert.Error(t, err)
+	})
+
+	t.Run("happy path, mutual TLS", func(t *testing.T) {
+		clientCert := newSelfSignedCert(t, "client")
+		leaf, err := x509.ParseCertificate(clientCert.Certificate[0])
+		assert.NoError(t, err)
+
+		// This server only talks to clients with a certificate it
+		// trusts.
+		clientCAs := x509.NewCertPool()
+		clientCAs.AddCert(leaf)
+		mtlsServer := &httptest.Server{
+			Listener: listenTempUnix(),
+			Config: &http.Server{Handler: jsonByDefault(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "client", r.TLS.PeerCertificates[0].Subject.CommonName)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`["Jack"]`))
+			}))},
+			TLS: &tls.Config{
+				ClientAuth: tls.RequireAndVerifyClientCert,
+				ClientCAs:  clientCAs,
+			},
+		}
+		mtlsServer.StartTLS()
+		defer mtlsServer.Close()
+
+		roots := x509.NewCertPool()
+		roots.AddCert(mtlsServer.Certificate())
+		client := NewClient(strings.Split(mtlsServer.URL, "//")[1], WithTLSConfig(&tls.Config{
+			RootCAs:      roots,
+			ServerName:   "example.com",
+			Certificates: []tls.Certificate{clientCert},
+		}))
+
+		// Calling a function to be tested.
+		users, err := client.GetUsers()
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+	})
+}
+
+func TestWithTLSHandshakeTimeout(t *testing.T) {
+	// The peer accepts connections but never says a word, so the
+	// handshake cannot complete.
+	l := listenTempUnix()
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client := NewClient(l.Addr().String(),
+		WithTLSConfig(&tls.Config{ServerName: "example.com"}),
+		WithTLSHandshakeTimeout(50*time.Millisecond),
+	)
+
+	// Calling a function to be tested.
+	start := time.Now()
+	_, err := client.GetUsers()
+
+	// The request fails once the handshake times out, instead of
+	// hanging.
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "tls handshake timeout after 50ms")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestWithInsecureSkipVerify(t *testing.T) {
+	// Create a router that routes http requests to specific handlers.
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`["Jack"]`))
+	})
+
+	// Create an UDS-based https server, whose certificate is
+	// self-signed, and register the router with a predefined mock
+	// handler.
+	fakeServer := NewUnixDomainSocketTLSServer(router)
+
+	// We should always close the http server at the end of the test
+	// to release related resources and delete the socket file.
+	defer fakeServer.Close()
+
+	// The format of the URL from the UDS-based mock https server is
+	// 'https:///tmp/uds-test-xxx/dummy.sock', we only need the part
+	// after '//', i.e. '/tmp/uds-test-xxx/dummy.sock'.
+	sock := strings.Split(fakeServer.URL, "//")[1]
+
+	t.Run("happy path, the self-signed certificate is accepted", func(t *testing.T) {
+		config := &tls.Config{ServerName: "example.com"}
+		client := NewClient(sock, WithTLSConfig(config), WithInsecureSkipVerify(true))
+
+		// Calling a function to be tested.
+		users, err := client.GetUsers()
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+
+		// The config given is left untouched.
+		assert.False(t, config.InsecureSkipVerify)
+	})
+
+	t.Run("unhappy path, the self-signed certificate is verified", func(t *testing.T) {
+		client := NewClient(sock, WithTLSConfig(&tls.Config{ServerName: "example.com"}))
+
+		// Calling a function to be tested.
+		_, err := client.GetUsers()
+
+		// The handshake fails, nothing is sent.
+		var unknownAuthority x509.UnknownAuthorityError
+		assert.ErrorAs(t, err, &unknownAuthority)
+	})
+
+	t.Run("happy path, no effect without TLS", func(t *testing.T) {
+		// Create an UDS-based http server speaking plain http.
+		plainServer := NewUnixDomainSocketServer(router)
+		defer plainServer.Close()
+
+		client := NewClient(strings.Split(plainServer.URL, "//")[1], WithInsecureSkipVerify(true))
+
+		// Calling a function to be tested.
+		users, err := client.GetUsers()
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jack"}, users)
+	})
+}
@@ -0,0 +1,31 @@
+package main
+
+import "context"
+
+// UserAPI is the user API of the server, as implemented by Client.
+// Code depending on UserAPI rather than on *Client can be tested with
+// a fake instead of a server. A fake embedding UserAPI only needs to
+// implement the methods the code under test calls, and keeps
+// compiling if methods are added.
+type UserAPI interface {
+	GetUsers() ([]string, error)
+	GetUsersContext(ctx context.Context) ([]string, error)
+	GetUserList() ([]CreateUserResponse, error)
+	GetUserListContext(ctx context.Context) ([]CreateUserResponse, error)
+	CreateUser(userName string) (*CreateUserResponse, error)
+	CreateUserContext(ctx context.Context, userName string) (*CreateUserResponse, error)
+	CreateUserWithParams(params CreateUserParams) (*CreateUserResponse, error)
+	CreateUserWithParamsContext(ctx context.Context, params CreateUserParams) (*CreateUserResponse, error)
+	GetUser(id string) (*CreateUserResponse, error)
+	GetUserContext(ctx context.Context, id string) (*CreateUserResponse, error)
+	UpdateUser(id, newName string) (*CreateUserResponse, error)
+	UpdateUserContext(ctx context.Context, id, newName string) (*CreateUserResponse, error)
+	PatchUser(id string, fields map[string]interface{}) (*CreateUserResponse, error)
+	PatchUserContext(ctx context.Context, id string, fields map[string]interface{}) (*CreateUserResponse, error)
+	DeleteUser(id string) error
+	DeleteUserContext(ctx context.Context, id string) error
+	HealthCheck() error
+	HealthCheckContext(ctx context.Context) error
+}
+
+var _ UserAPI = (*Client)(nil)
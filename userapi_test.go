@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeUserAPI is a UserAPI keeping the users in memory. Embedding
+// UserAPI, it only implements the methods used by renameAll.
+type fakeUserAPI struct {
+	UserAPI
+	users map[string]string
+}
+
+func (f *fakeUserAPI) GetUserList() ([]CreateUserResponse, error) {
+	users := make([]CreateUserResponse, 0, len(f.users))
+	for id, name := range f.users {
+		users = append(users, CreateUserResponse{ID: id, Name: name})
+	}
+	return users, nil
+}
+
+func (f *fakeUserAPI) UpdateUser(id, newName string) (*CreateUserResponse, error) {
+	if _, ok := f.users[id]; !ok {
+		return nil, ErrUserNotFound
+	}
+	f.users[id] = newName
+	return &CreateUserResponse{ID: id, Name: newName}, nil
+}
+
+// renameAll stands for the code of a consumer depending on UserAPI.
+func renameAll(api UserAPI, suffix string) error {
+	users, err := api.GetUserList()
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if _, err := api.UpdateUser(user.ID, user.Name+suffix); err != nil {
+			return fmt.Errorf("rename %s: %w", user.ID, err)
+		}
+	}
+	return nil
+}
+
+func TestUserAPI(t *testing.T) {
+	t.Run("happy path, a fake stands in for the Client", func(t *testing.T) {
+		fake := &fakeUserAPI{users: map[string]string{"ABC-111": "Jack", "ABC-222": "Marry"}}
+
+		// Calling a function to be tested.
+		err := renameAll(fake, " (old)")
+
+		// Test the results of the function as we expect.
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"ABC-111": "Jack (old)", "ABC-222": "Marry (old)"}, fake.users)
+	})
+
+	t.Run("happy path, the Client is a UserAPI", func(t *testing.T) {
+		var api UserAPI = NewClient("/nonexistent/dummy.sock")
+
+		// The Client fails to reach the server, which the consumer
+		// reports.
+		err := renameAll(api, " (old)")
+		var connErr *ConnectError
+		assert.ErrorAs(t, err, &connErr)
+	})
+}